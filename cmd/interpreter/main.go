@@ -3,9 +3,10 @@ package main
 import (
 	"bytes"
 	"flag"
-	"log"
+	"fmt"
 	"os"
 
+	"github.com/bootun/mini-tun/pkg/diagnostics"
 	"github.com/bootun/mini-tun/pkg/interpreter"
 	"github.com/bootun/mini-tun/pkg/lexer"
 	"github.com/bootun/mini-tun/pkg/parser"
@@ -32,37 +33,51 @@ func main() {
 	fileName := os.Args[1]
 	sourceCode, err := os.Open(fileName)
 	if err != nil {
-		log.Printf("failed to open %v: %v", fileName, err)
+		report("", fmt.Errorf("failed to open %v: %v", fileName, err))
 		os.Exit(1)
 	}
 	var buf bytes.Buffer
 	if _, err := buf.ReadFrom(sourceCode); err != nil {
-		log.Printf("failed to read source code: %v", err)
+		report("", fmt.Errorf("failed to read source code: %v", err))
 		os.Exit(2)
 	}
 	input := buf.String()
-	l := lexer.New(input)
-	newParser, err := parser.New(l)
+	l := lexer.New(input, lexer.WithFilename(fileName))
+	program, err := parser.New(l).Parse()
 	if err != nil {
-		log.Printf("failed to create parser: %v", err)
+		report(input, err)
 		os.Exit(3)
 	}
-	program, err := newParser.Parse()
-	if err != nil {
-		log.Printf("failed to parse program: %v", err)
-		os.Exit(4)
-	}
 
 	if err := typecheck.NewChecker(program).Check(); err != nil {
-		log.Printf("type check error: %v\n", err)
-		os.Exit(5)
+		report(input, err)
+		os.Exit(4)
 	}
 	// fmt.Printf("pass type check")
 
 	vm := interpreter.NewInterpreter(program)
 	if err := vm.Exec(); err != nil {
-		log.Printf("execute error: %v\n", err)
-		os.Exit(6)
+		report(input, err)
+		os.Exit(5)
 	}
 
 }
+
+// report 把错误中的诊断信息连同源码片段输出到标准错误, 标准输出是终端时使用彩色文本
+func report(source string, err error) {
+	var opts []diagnostics.RenderOption
+	if isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" {
+		opts = append(opts, diagnostics.WithColor())
+	}
+	list := diagnostics.FromError(err)
+	if renderErr := diagnostics.NewRenderer(source, opts...).Render(os.Stderr, list); renderErr != nil {
+		// 渲染失败时退而输出不带源码片段的错误信息
+		fmt.Fprintf(os.Stderr, "%v\nfailed to render diagnostics: %v\n", list, renderErr)
+	}
+}
+
+// isTerminal 判断文件是否是终端
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
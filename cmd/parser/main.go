@@ -17,11 +17,7 @@ let add = function(a, b) {
 let e = add(1,add(a,b))
 `
 	l := lexer.New(input)
-	newParser, err := parser.New(l)
-	if err != nil {
-		panic(err)
-	}
-	program, err := newParser.Parse()
+	program, err := parser.New(l).Parse()
 	if err != nil {
 		panic(err)
 	}
@@ -19,11 +19,7 @@ let add = function(a, b) {
 let e = add(10,add(a))
 `
 	l := lexer.New(input)
-	newParser, err := parser.New(l)
-	if err != nil {
-		panic(err)
-	}
-	program, err := newParser.Parse()
+	program, err := parser.New(l).Parse()
 	if err != nil {
 		panic(err)
 	}
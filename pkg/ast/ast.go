@@ -3,6 +3,7 @@ package ast
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/bootun/mini-tun/pkg/token"
@@ -11,15 +12,27 @@ import (
 const (
 	NodeTypeExpression = "Expression"
 	NodeTypeStatement  = "Statement"
+	NodeTypeType       = "Type"
 )
 
 type NodeInfo struct {
-	NodeType string // 节点类型
-	NodeName string // 节点实际名称
+	NodeType string     // 节点类型
+	NodeName string     // 节点实际名称
+	Span     token.Span // 节点在源码中的位置
+}
+
+func (n *NodeInfo) GetSpan() token.Span {
+	return n.Span
+}
+
+func (n *NodeInfo) SetSpan(span token.Span) {
+	n.Span = span
 }
 
 type Node interface {
 	TokenLiteral() string
+	GetSpan() token.Span
+	SetSpan(span token.Span)
 }
 
 type Statement interface {
@@ -40,8 +53,9 @@ func (p *Program) JSON() string {
 }
 
 type VariableAssignment struct {
-	NodeInfo     NodeInfo
+	NodeInfo     `json:"NodeInfo"`
 	VariableName string
+	Type         TypeExpression // 变量的类型标注, 没有标注时为nil
 	Value        Expression
 }
 
@@ -57,6 +71,9 @@ func NewVariableAssignment(variableName string, value Expression) *VariableAssig
 }
 
 func (v *VariableAssignment) TokenLiteral() string {
+	if v.Type != nil {
+		return fmt.Sprintf("let %s: %s = %s", v.VariableName, v.Type.TokenLiteral(), v.Value.TokenLiteral())
+	}
 	return fmt.Sprintf("let %s = %s", v.VariableName, v.Value.TokenLiteral())
 }
 
@@ -64,9 +81,33 @@ func (v *VariableAssignment) Name() string {
 	return "VariableAssignment"
 }
 
+// 对已声明变量的赋值, 如 x = 1, Operator 也可以是 += -= *= /= 等复合赋值运算符
+type Assignment struct {
+	NodeInfo     `json:"NodeInfo"`
+	VariableName string
+	Operator     token.Token
+	Value        Expression
+}
+
+func NewAssignment(variableName string, operator token.Token, value Expression) *Assignment {
+	return &Assignment{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeStatement,
+			NodeName: "Assignment",
+		},
+		VariableName: variableName,
+		Operator:     operator,
+		Value:        value,
+	}
+}
+
+func (a *Assignment) TokenLiteral() string {
+	return fmt.Sprintf("%s %s %s", a.VariableName, a.Operator.Literal, a.Value.TokenLiteral())
+}
+
 // 字面值常量表达式
 type LiteralExpression struct {
-	NodeInfo NodeInfo
+	NodeInfo `json:"NodeInfo"`
 	Value    int
 }
 
@@ -84,31 +125,98 @@ func (l *LiteralExpression) TokenLiteral() string {
 	return fmt.Sprintf("%d", l.Value)
 }
 
+// 浮点数字面值
+type FloatLiteral struct {
+	NodeInfo `json:"NodeInfo"`
+	Value    float64
+}
+
+func NewFloatLiteral(value float64) *FloatLiteral {
+	return &FloatLiteral{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeExpression,
+			NodeName: "FloatLiteral",
+		},
+		Value: value,
+	}
+}
+
+func (f *FloatLiteral) TokenLiteral() string {
+	return token.FormatFloat(f.Value)
+}
+
+// 布尔字面值 true/false
+type BooleanLiteral struct {
+	NodeInfo `json:"NodeInfo"`
+	Value    bool
+}
+
+func NewBooleanLiteral(value bool) *BooleanLiteral {
+	return &BooleanLiteral{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeExpression,
+			NodeName: "BooleanLiteral",
+		},
+		Value: value,
+	}
+}
+
+func (b *BooleanLiteral) TokenLiteral() string {
+	return fmt.Sprintf("%t", b.Value)
+}
+
+// 字符串字面值, Value 为转义后的内容
+type StringLiteral struct {
+	NodeInfo `json:"NodeInfo"`
+	Value    string
+}
+
+func NewStringLiteral(value string) *StringLiteral {
+	return &StringLiteral{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeExpression,
+			NodeName: "StringLiteral",
+		},
+		Value: value,
+	}
+}
+
+func (s *StringLiteral) TokenLiteral() string {
+	return strconv.Quote(s.Value)
+}
+
+// 函数调用, Callee 可以是任意表达式, 如 f(1)、makeAdder(1)(2)、ops[0](a, b)
 type FunctionCall struct {
-	NodeInfo     NodeInfo
-	FunctionName string
-	Arguments    []Expression
+	NodeInfo  `json:"NodeInfo"`
+	Callee    Expression
+	Arguments []Expression
 }
 
-func NewFunctionCall(functionName string, arguments []Expression) *FunctionCall {
+func NewFunctionCall(callee Expression, arguments []Expression) *FunctionCall {
 	return &FunctionCall{
 		NodeInfo: NodeInfo{
 			NodeType: NodeTypeExpression,
 			NodeName: "FunctionCall",
 		},
-		FunctionName: functionName,
-		Arguments:    arguments,
+		Callee:    callee,
+		Arguments: arguments,
 	}
 }
 
 func (f *FunctionCall) TokenLiteral() string {
-	return fmt.Sprintf("%s(%s)", f.FunctionName, f.Arguments)
+	var args []string
+	for _, arg := range f.Arguments {
+		args = append(args, arg.TokenLiteral())
+	}
+	return fmt.Sprintf("%s(%s)", f.Callee.TokenLiteral(), strings.Join(args, ","))
 }
 
 type FunctionLiteral struct {
-	NodeInfo   NodeInfo
-	Parameters []*IdentifierExpression
-	Body       *BlockStatement
+	NodeInfo       `json:"NodeInfo"`
+	TypeParameters []*IdentifierExpression // 类型参数, 如 function<T>(x: T): T 中的T
+	Parameters     []*IdentifierExpression
+	ReturnType     TypeExpression // 返回值的类型标注, 没有标注时为nil
+	Body           *BlockStatement
 }
 
 func NewFunctionLiteral(parameters []*IdentifierExpression, body *BlockStatement) *FunctionLiteral {
@@ -124,13 +232,29 @@ func NewFunctionLiteral(parameters []*IdentifierExpression, body *BlockStatement
 
 func (f *FunctionLiteral) TokenLiteral() string {
 	var buf strings.Builder
-	buf.WriteString("function(")
+	buf.WriteString("function")
+	if len(f.TypeParameters) > 0 {
+		var typeParams []string
+		for _, param := range f.TypeParameters {
+			typeParams = append(typeParams, param.TokenLiteral())
+		}
+		buf.WriteString("<" + strings.Join(typeParams, ",") + ">")
+	}
+	buf.WriteString("(")
 	var params []string
 	for _, param := range f.Parameters {
-		params = append(params, param.TokenLiteral())
+		if param.Type != nil {
+			params = append(params, param.TokenLiteral()+": "+param.Type.TokenLiteral())
+		} else {
+			params = append(params, param.TokenLiteral())
+		}
 	}
 	buf.WriteString(strings.Join(params, ","))
-	buf.WriteString(") {")
+	buf.WriteString(")")
+	if f.ReturnType != nil {
+		buf.WriteString(": " + f.ReturnType.TokenLiteral())
+	}
+	buf.WriteString(" {")
 	for _, stmt := range f.Body.Statements {
 		buf.WriteString(stmt.TokenLiteral())
 		buf.WriteString(";")
@@ -139,8 +263,30 @@ func (f *FunctionLiteral) TokenLiteral() string {
 	return buf.String()
 }
 
+// 具名函数声明 function name(params) { ... }, 会在所在语句块执行前提升, 因此可以相互递归调用
+type FunctionDeclaration struct {
+	NodeInfo `json:"NodeInfo"`
+	Name     string
+	Function *FunctionLiteral
+}
+
+func NewFunctionDeclaration(name string, function *FunctionLiteral) *FunctionDeclaration {
+	return &FunctionDeclaration{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeStatement,
+			NodeName: "FunctionDeclaration",
+		},
+		Name:     name,
+		Function: function,
+	}
+}
+
+func (f *FunctionDeclaration) TokenLiteral() string {
+	return "function " + f.Name + strings.TrimPrefix(f.Function.TokenLiteral(), "function")
+}
+
 type BlockStatement struct {
-	NodeInfo   NodeInfo
+	NodeInfo   `json:"NodeInfo"`
 	Statements []Statement
 }
 
@@ -155,11 +301,245 @@ func NewBlockStatement(statements []Statement) *BlockStatement {
 }
 
 func (b *BlockStatement) TokenLiteral() string {
-	return fmt.Sprintf("{%s}", b.Statements)
+	var buf strings.Builder
+	buf.WriteString("{")
+	for _, stmt := range b.Statements {
+		buf.WriteString(stmt.TokenLiteral())
+		buf.WriteString(";")
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// if语句, Alternative 为 else 分支, 可以是 *BlockStatement 或 *IfStatement(else if), 没有else时为nil
+type IfStatement struct {
+	NodeInfo    `json:"NodeInfo"`
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative Statement
+}
+
+func NewIfStatement(condition Expression, consequence *BlockStatement, alternative Statement) *IfStatement {
+	return &IfStatement{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeStatement,
+			NodeName: "IfStatement",
+		},
+		Condition:   condition,
+		Consequence: consequence,
+		Alternative: alternative,
+	}
+}
+
+func (i *IfStatement) TokenLiteral() string {
+	var buf strings.Builder
+	buf.WriteString("if ")
+	buf.WriteString(i.Condition.TokenLiteral())
+	buf.WriteString(" ")
+	buf.WriteString(i.Consequence.TokenLiteral())
+	if i.Alternative != nil {
+		buf.WriteString(" else ")
+		buf.WriteString(i.Alternative.TokenLiteral())
+	}
+	return buf.String()
+}
+
+// 表达式语句, 表达式的值会被丢弃, 如单独一行的函数调用
+type ExpressionStatement struct {
+	NodeInfo   `json:"NodeInfo"`
+	Expression Expression
+}
+
+func NewExpressionStatement(expression Expression) *ExpressionStatement {
+	return &ExpressionStatement{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeStatement,
+			NodeName: "ExpressionStatement",
+		},
+		Expression: expression,
+	}
+}
+
+func (e *ExpressionStatement) TokenLiteral() string {
+	return e.Expression.TokenLiteral()
+}
+
+type WhileStatement struct {
+	NodeInfo  `json:"NodeInfo"`
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func NewWhileStatement(condition Expression, body *BlockStatement) *WhileStatement {
+	return &WhileStatement{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeStatement,
+			NodeName: "WhileStatement",
+		},
+		Condition: condition,
+		Body:      body,
+	}
+}
+
+func (w *WhileStatement) TokenLiteral() string {
+	return fmt.Sprintf("while %s %s", w.Condition.TokenLiteral(), w.Body.TokenLiteral())
+}
+
+// C风格的for循环, Init、Condition、Post 均可以为nil, Condition 为nil时表示条件恒为真
+type ForStatement struct {
+	NodeInfo  `json:"NodeInfo"`
+	Init      Statement
+	Condition Expression
+	Post      Statement
+	Body      *BlockStatement
+}
+
+func NewForStatement(init Statement, condition Expression, post Statement, body *BlockStatement) *ForStatement {
+	return &ForStatement{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeStatement,
+			NodeName: "ForStatement",
+		},
+		Init:      init,
+		Condition: condition,
+		Post:      post,
+		Body:      body,
+	}
+}
+
+func (f *ForStatement) TokenLiteral() string {
+	var buf strings.Builder
+	buf.WriteString("for ")
+	if f.Init != nil {
+		buf.WriteString(f.Init.TokenLiteral())
+	}
+	buf.WriteString("; ")
+	if f.Condition != nil {
+		buf.WriteString(f.Condition.TokenLiteral())
+	}
+	buf.WriteString("; ")
+	if f.Post != nil {
+		buf.WriteString(f.Post.TokenLiteral())
+		buf.WriteString(" ")
+	}
+	buf.WriteString(f.Body.TokenLiteral())
+	return buf.String()
+}
+
+// 对数组元素或字典的赋值, 如 a[i] = 1、m["k"] = 1, Operator 也可以是复合赋值运算符
+type IndexAssignment struct {
+	NodeInfo `json:"NodeInfo"`
+	Target   *IndexExpression
+	Operator token.Token
+	Value    Expression
+}
+
+func NewIndexAssignment(target *IndexExpression, operator token.Token, value Expression) *IndexAssignment {
+	return &IndexAssignment{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeStatement,
+			NodeName: "IndexAssignment",
+		},
+		Target:   target,
+		Operator: operator,
+		Value:    value,
+	}
+}
+
+func (i *IndexAssignment) TokenLiteral() string {
+	return fmt.Sprintf("%s %s %s", i.Target.TokenLiteral(), i.Operator.Literal, i.Value.TokenLiteral())
+}
+
+// 对结构体字段的赋值, 如 p.x = 1, Operator 也可以是复合赋值运算符
+type FieldAssignment struct {
+	NodeInfo `json:"NodeInfo"`
+	Target   *FieldAccess
+	Operator token.Token
+	Value    Expression
+}
+
+func NewFieldAssignment(target *FieldAccess, operator token.Token, value Expression) *FieldAssignment {
+	return &FieldAssignment{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeStatement,
+			NodeName: "FieldAssignment",
+		},
+		Target:   target,
+		Operator: operator,
+		Value:    value,
+	}
+}
+
+func (f *FieldAssignment) TokenLiteral() string {
+	return fmt.Sprintf("%s %s %s", f.Target.TokenLiteral(), f.Operator.Literal, f.Value.TokenLiteral())
+}
+
+// 结构体声明 struct Point { x, y }, 只能出现在程序的最外层
+type StructDecl struct {
+	NodeInfo `json:"NodeInfo"`
+	Name     string
+	Fields   []*IdentifierExpression
+}
+
+func NewStructDecl(name string, fields []*IdentifierExpression) *StructDecl {
+	return &StructDecl{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeStatement,
+			NodeName: "StructDecl",
+		},
+		Name:   name,
+		Fields: fields,
+	}
+}
+
+func (s *StructDecl) TokenLiteral() string {
+	fields := make([]string, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		if field.Type != nil {
+			fields = append(fields, field.Value+": "+field.Type.TokenLiteral())
+		} else {
+			fields = append(fields, field.Value)
+		}
+	}
+	return fmt.Sprintf("struct %s { %s }", s.Name, strings.Join(fields, ", "))
+}
+
+type BreakStatement struct {
+	NodeInfo `json:"NodeInfo"`
+}
+
+func NewBreakStatement() *BreakStatement {
+	return &BreakStatement{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeStatement,
+			NodeName: "BreakStatement",
+		},
+	}
+}
+
+func (b *BreakStatement) TokenLiteral() string {
+	return "break"
+}
+
+type ContinueStatement struct {
+	NodeInfo `json:"NodeInfo"`
+}
+
+func NewContinueStatement() *ContinueStatement {
+	return &ContinueStatement{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeStatement,
+			NodeName: "ContinueStatement",
+		},
+	}
+}
+
+func (c *ContinueStatement) TokenLiteral() string {
+	return "continue"
 }
 
 type ReturnStatement struct {
-	NodeInfo    NodeInfo
+	NodeInfo    `json:"NodeInfo"`
 	ReturnValue Expression
 }
 
@@ -178,7 +558,7 @@ func (r *ReturnStatement) TokenLiteral() string {
 }
 
 type ComplexExpression struct {
-	NodeInfo NodeInfo
+	NodeInfo `json:"NodeInfo"`
 	Left     Expression
 	Operator token.Token
 	Right    Expression
@@ -200,9 +580,56 @@ func (c *ComplexExpression) TokenLiteral() string {
 	return fmt.Sprintf("%s %s %s", c.Left.TokenLiteral(), c.Operator.Literal, c.Right.TokenLiteral())
 }
 
+// 逻辑表达式 && 和 ||, 右侧表达式只在需要时才会被求值
+type LogicalExpression struct {
+	NodeInfo `json:"NodeInfo"`
+	Left     Expression
+	Operator token.Token
+	Right    Expression
+}
+
+func NewLogicalExpression(left Expression, operator token.Token, right Expression) *LogicalExpression {
+	return &LogicalExpression{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeExpression,
+			NodeName: "LogicalExpression",
+		},
+		Left:     left,
+		Operator: operator,
+		Right:    right,
+	}
+}
+
+func (l *LogicalExpression) TokenLiteral() string {
+	return fmt.Sprintf("%s %s %s", l.Left.TokenLiteral(), l.Operator.Literal, l.Right.TokenLiteral())
+}
+
+// 前缀表达式, 如 -x, +x, !x
+type PrefixExpression struct {
+	NodeInfo `json:"NodeInfo"`
+	Operator token.Token
+	Right    Expression
+}
+
+func NewPrefixExpression(operator token.Token, right Expression) *PrefixExpression {
+	return &PrefixExpression{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeExpression,
+			NodeName: "PrefixExpression",
+		},
+		Operator: operator,
+		Right:    right,
+	}
+}
+
+func (p *PrefixExpression) TokenLiteral() string {
+	return fmt.Sprintf("%s%s", p.Operator.Literal, p.Right.TokenLiteral())
+}
+
 type IdentifierExpression struct {
-	NodeInfo NodeInfo
-	Value    string // 标识符名称
+	NodeInfo `json:"NodeInfo"`
+	Value    string         // 标识符名称
+	Type     TypeExpression // 函数参数或结构体字段的类型标注, 没有标注时为nil
 }
 
 func NewIdentifierExpression(value string) *IdentifierExpression {
@@ -218,3 +645,262 @@ func NewIdentifierExpression(value string) *IdentifierExpression {
 func (i *IdentifierExpression) TokenLiteral() string {
 	return i.Value
 }
+
+// 数组字面值 [1, 2, 3]
+type ArrayLiteral struct {
+	NodeInfo `json:"NodeInfo"`
+	Elements []Expression
+}
+
+func NewArrayLiteral(elements []Expression) *ArrayLiteral {
+	return &ArrayLiteral{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeExpression,
+			NodeName: "ArrayLiteral",
+		},
+		Elements: elements,
+	}
+}
+
+func (a *ArrayLiteral) TokenLiteral() string {
+	elements := make([]string, 0, len(a.Elements))
+	for _, element := range a.Elements {
+		elements = append(elements, element.TokenLiteral())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// 下标表达式 a[i] 或 m[key]
+type IndexExpression struct {
+	NodeInfo `json:"NodeInfo"`
+	Left     Expression
+	Index    Expression
+}
+
+func NewIndexExpression(left Expression, index Expression) *IndexExpression {
+	return &IndexExpression{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeExpression,
+			NodeName: "IndexExpression",
+		},
+		Left:  left,
+		Index: index,
+	}
+}
+
+func (i *IndexExpression) TokenLiteral() string {
+	return fmt.Sprintf("%s[%s]", i.Left.TokenLiteral(), i.Index.TokenLiteral())
+}
+
+// 切片表达式 a[lo:hi], Low 和 High 都可以省略, 省略时为nil
+type SliceExpression struct {
+	NodeInfo `json:"NodeInfo"`
+	Left     Expression
+	Low      Expression
+	High     Expression
+}
+
+func NewSliceExpression(left Expression, low Expression, high Expression) *SliceExpression {
+	return &SliceExpression{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeExpression,
+			NodeName: "SliceExpression",
+		},
+		Left: left,
+		Low:  low,
+		High: high,
+	}
+}
+
+func (s *SliceExpression) TokenLiteral() string {
+	var low, high string
+	if s.Low != nil {
+		low = s.Low.TokenLiteral()
+	}
+	if s.High != nil {
+		high = s.High.TokenLiteral()
+	}
+	return fmt.Sprintf("%s[%s:%s]", s.Left.TokenLiteral(), low, high)
+}
+
+// 字典字面值 {"k": v, 1: w}, Pairs 按源码中的顺序排列
+type MapLiteral struct {
+	NodeInfo `json:"NodeInfo"`
+	Pairs    []*MapPair
+}
+
+// MapPair 是字典字面值中的一个键值对
+type MapPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func NewMapLiteral(pairs []*MapPair) *MapLiteral {
+	return &MapLiteral{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeExpression,
+			NodeName: "MapLiteral",
+		},
+		Pairs: pairs,
+	}
+}
+
+func (m *MapLiteral) TokenLiteral() string {
+	pairs := make([]string, 0, len(m.Pairs))
+	for _, pair := range m.Pairs {
+		pairs = append(pairs, pair.Key.TokenLiteral()+": "+pair.Value.TokenLiteral())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// 结构体字面值 Point{x: 1, y: 2}, Fields 按源码中的顺序排列
+type StructLiteral struct {
+	NodeInfo `json:"NodeInfo"`
+	Name     string
+	Fields   []*StructField
+}
+
+// StructField 是结构体字面值中的一个字段, Span 为字段名的位置
+type StructField struct {
+	Name  string
+	Span  token.Span
+	Value Expression
+}
+
+func NewStructLiteral(name string, fields []*StructField) *StructLiteral {
+	return &StructLiteral{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeExpression,
+			NodeName: "StructLiteral",
+		},
+		Name:   name,
+		Fields: fields,
+	}
+}
+
+func (s *StructLiteral) TokenLiteral() string {
+	fields := make([]string, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		fields = append(fields, field.Name+": "+field.Value.TokenLiteral())
+	}
+	return s.Name + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// 字段访问 p.x
+type FieldAccess struct {
+	NodeInfo `json:"NodeInfo"`
+	Left     Expression
+	Field    string
+}
+
+func NewFieldAccess(left Expression, field string) *FieldAccess {
+	return &FieldAccess{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeExpression,
+			NodeName: "FieldAccess",
+		},
+		Left:  left,
+		Field: field,
+	}
+}
+
+func (f *FieldAccess) TokenLiteral() string {
+	return f.Left.TokenLiteral() + "." + f.Field
+}
+
+// TypeExpression 表示源码中的类型标注, 如 int、[]string、map[string]int、function(int) bool
+type TypeExpression interface {
+	Node
+}
+
+// 具名类型 int、float、bool、string 或结构体的名字
+type NamedType struct {
+	NodeInfo `json:"NodeInfo"`
+	Name     string
+}
+
+func NewNamedType(name string) *NamedType {
+	return &NamedType{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeType,
+			NodeName: "NamedType",
+		},
+		Name: name,
+	}
+}
+
+func (n *NamedType) TokenLiteral() string {
+	return n.Name
+}
+
+// 数组类型 []T
+type ArrayType struct {
+	NodeInfo `json:"NodeInfo"`
+	Elem     TypeExpression
+}
+
+func NewArrayType(elem TypeExpression) *ArrayType {
+	return &ArrayType{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeType,
+			NodeName: "ArrayType",
+		},
+		Elem: elem,
+	}
+}
+
+func (a *ArrayType) TokenLiteral() string {
+	return "[]" + a.Elem.TokenLiteral()
+}
+
+// 字典类型 map[K]V
+type MapType struct {
+	NodeInfo `json:"NodeInfo"`
+	Key      TypeExpression
+	Value    TypeExpression
+}
+
+func NewMapType(key TypeExpression, value TypeExpression) *MapType {
+	return &MapType{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeType,
+			NodeName: "MapType",
+		},
+		Key:   key,
+		Value: value,
+	}
+}
+
+func (m *MapType) TokenLiteral() string {
+	return "map[" + m.Key.TokenLiteral() + "]" + m.Value.TokenLiteral()
+}
+
+// 函数类型 function(T1, T2) R, 没有返回值的函数省略R
+type FunctionType struct {
+	NodeInfo `json:"NodeInfo"`
+	Params   []TypeExpression
+	Result   TypeExpression // 没有返回值时为nil
+}
+
+func NewFunctionType(params []TypeExpression, result TypeExpression) *FunctionType {
+	return &FunctionType{
+		NodeInfo: NodeInfo{
+			NodeType: NodeTypeType,
+			NodeName: "FunctionType",
+		},
+		Params: params,
+		Result: result,
+	}
+}
+
+func (f *FunctionType) TokenLiteral() string {
+	params := make([]string, 0, len(f.Params))
+	for _, param := range f.Params {
+		params = append(params, param.TokenLiteral())
+	}
+	s := "function(" + strings.Join(params, ", ") + ")"
+	if f.Result != nil {
+		s += " " + f.Result.TokenLiteral()
+	}
+	return s
+}
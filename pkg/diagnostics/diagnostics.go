@@ -0,0 +1,128 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bootun/mini-tun/pkg/token"
+)
+
+// Severity 表示诊断信息的严重程度
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Code 标识诊断信息的类别, 同一类问题使用相同的代码
+type Code string
+
+const (
+	InvalidToken     Code = "invalid-token"     // 无法识别的字符、未结束的字符串等词法错误
+	SyntaxError      Code = "syntax"            // 不符合语法的token序列
+	Undefined        Code = "undefined"         // 使用了未声明的变量、类型、结构体或字段
+	Redeclared       Code = "redeclared"        // 重复声明的结构体、字段、类型参数或字典键
+	TypeMismatch     Code = "type-mismatch"     // 值的类型和期望的类型不同
+	InvalidOperation Code = "invalid-operation" // 运算、调用、索引或字段访问不适用于值的类型
+	ArgumentCount    Code = "argument-count"    // 调用时参数的个数和函数的参数个数不同
+	MissingReturn    Code = "missing-return"    // 有返回值的函数缺少return语句
+	InvalidStatement Code = "invalid-statement" // 语句不能出现在当前位置, 如循环外的break
+	RuntimeError     Code = "runtime"           // 程序运行时发生的错误, 如除以0、数组越界
+)
+
+// Diagnostic 表示源码中的一个问题
+type Diagnostic struct {
+	Severity Severity
+	Span     token.Span // 问题所在的区间
+	Code     Code
+	Message  string
+	Notes    []Note // 补充说明, 如重复声明时指向第一次声明的位置
+}
+
+// Note 表示诊断信息中指向另一段源码的补充说明
+type Note struct {
+	Span    token.Span
+	Message string
+}
+
+// Errorf 创建一个错误级别的诊断信息
+func Errorf(span token.Span, code Code, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Severity: Error,
+		Span:     span,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// WithNote 添加一条补充说明, 返回诊断信息自身以便链式调用
+func (d *Diagnostic) WithNote(span token.Span, format string, args ...interface{}) *Diagnostic {
+	d.Notes = append(d.Notes, Note{Span: span, Message: fmt.Sprintf(format, args...)})
+	return d
+}
+
+// Error 返回 file:line:col: message 形式的错误信息
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%v: %s", d.Span.Start, d.Message)
+}
+
+// List 表示一组诊断信息, 可以作为error返回
+type List []*Diagnostic
+
+// Add 添加一条诊断信息
+func (l *List) Add(d *Diagnostic) {
+	*l = append(*l, d)
+}
+
+// Sort 按照在源码中的位置排序, 位置相同的诊断信息保持原来的顺序
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[i].Span.Start.Offset < l[j].Span.Start.Offset
+	})
+}
+
+// Error 返回所有诊断信息, 每行一条
+func (l List) Error() string {
+	messages := make([]string, 0, len(l))
+	for _, d := range l {
+		messages = append(messages, d.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Err 没有诊断信息时返回nil, 否则返回列表自身
+func (l List) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// FromError 从error中取出诊断信息, 不是诊断信息的错误会被转换为没有位置信息的诊断信息
+func FromError(err error) List {
+	if err == nil {
+		return nil
+	}
+	var list List
+	if errors.As(err, &list) {
+		return list
+	}
+	var d *Diagnostic
+	if errors.As(err, &d) {
+		return List{d}
+	}
+	return List{{Severity: Error, Message: err.Error()}}
+}
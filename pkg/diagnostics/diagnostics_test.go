@@ -0,0 +1,63 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bootun/mini-tun/pkg/token"
+)
+
+// span 返回从line:column开始, 长度为length的区间
+func span(offset, line, column, length int) token.Span {
+	return token.Span{
+		Start: token.Position{Filename: "main.tun", Offset: offset, Line: line, Column: column},
+		End:   token.Position{Filename: "main.tun", Offset: offset + length, Line: line, Column: column + length},
+	}
+}
+
+func TestList(t *testing.T) {
+	var list List
+	if err := list.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+	list.Add(Errorf(span(12, 2, 3, 1), TypeMismatch, "type mismatch: expected %s, but got %s", "int", "string"))
+	list.Add(Errorf(span(0, 1, 1, 3), SyntaxError, "unexpected token"))
+	list.Sort()
+	want := "main.tun:1:1: unexpected token\nmain.tun:2:3: type mismatch: expected int, but got string"
+	if got := list.Err().Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestWithNote(t *testing.T) {
+	d := Errorf(span(10, 2, 1, 5), Redeclared, "struct %s redeclared", "P").
+		WithNote(span(0, 1, 1, 5), "first declared here")
+	if len(d.Notes) != 1 || d.Notes[0].Message != "first declared here" || d.Notes[0].Span.Start.Line != 1 {
+		t.Errorf("Notes = %+v, want one note at line 1", d.Notes)
+	}
+	if d.Severity != Error || d.Severity.String() != "error" {
+		t.Errorf("Severity = %v, want error", d.Severity)
+	}
+}
+
+func TestFromError(t *testing.T) {
+	d := Errorf(span(0, 1, 1, 1), Undefined, "undefined variable: x")
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "nil", err: nil, want: ""},
+		{name: "list", err: List{d, d}, want: d.Error() + "\n" + d.Error()},
+		{name: "wrapped_diagnostic", err: fmt.Errorf("check: %w", d), want: d.Error()},
+		{name: "plain_error", err: errors.New("boom"), want: "-: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromError(tt.err).Error(); got != tt.want {
+				t.Errorf("FromError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bootun/mini-tun/pkg/token"
+)
+
+// 彩色模式下使用的ANSI转义序列
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[1;33m"
+	ansiBlue   = "\x1b[1;34m"
+)
+
+// Renderer 把诊断信息渲染为带有源码片段的文本, 例如:
+//
+//	error[redeclared]: struct P redeclared
+//	 --> main.tun:2:1
+//	  |
+//	1 | struct P { x }
+//	  | -------------- first declared here
+//	2 | struct P { y }
+//	  | ^^^^^^^^^^^^^^
+type Renderer struct {
+	lines []string // 源码中的每一行, 不包含换行符
+	color bool     // 是否使用ANSI转义序列输出彩色文本
+}
+
+type RenderOption func(*Renderer)
+
+// WithColor 让渲染器输出彩色文本, 适用于输出到终端的情况
+func WithColor() RenderOption {
+	return func(r *Renderer) {
+		r.color = true
+	}
+}
+
+// NewRenderer 创建渲染器, source是诊断信息所在的源码
+func NewRenderer(source string, opts ...RenderOption) *Renderer {
+	r := &Renderer{lines: strings.Split(source, "\n")}
+	for i, line := range r.lines {
+		r.lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// label 表示源码片段中的一条下划线
+type label struct {
+	span    token.Span
+	message string
+	primary bool // 诊断信息本身所在的区间用 ^ 标记, 补充说明用 - 标记
+}
+
+// Render 依次渲染所有诊断信息, 诊断信息之间用空行分隔
+func (r *Renderer) Render(w io.Writer, list List) error {
+	for i, d := range list {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, r.Format(d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Format 渲染一条诊断信息, 没有位置信息的诊断信息只输出标题
+func (r *Renderer) Format(d *Diagnostic) string {
+	var buf strings.Builder
+	severityColor := ansiRed
+	if d.Severity == Warning {
+		severityColor = ansiYellow
+	}
+	title := d.Severity.String()
+	if d.Code != "" {
+		title += "[" + string(d.Code) + "]"
+	}
+	buf.WriteString(r.paint(severityColor, title) + r.paint(ansiBold, ": "+d.Message) + "\n")
+
+	labels := []label{{span: d.Span, primary: true}}
+	var footnotes []string
+	for _, note := range d.Notes {
+		if r.visible(note.Span) {
+			labels = append(labels, label{span: note.Span, message: note.Message})
+		} else {
+			footnotes = append(footnotes, note.Message)
+		}
+	}
+	if !r.visible(d.Span) {
+		// 没有位置信息时补充说明也无法指向源码
+		for _, l := range labels[1:] {
+			footnotes = append(footnotes, l.message)
+		}
+		labels = nil
+	}
+
+	width := 1
+	for _, l := range labels {
+		width = max(width, len(strconv.Itoa(l.span.Start.Line)))
+	}
+	gutter := strings.Repeat(" ", width)
+	if len(labels) > 0 {
+		buf.WriteString(gutter + r.paint(ansiBlue, "-->") + " " + d.Span.Start.String() + "\n")
+		buf.WriteString(gutter + " " + r.paint(ansiBlue, "|") + "\n")
+		r.writeSnippet(&buf, labels, width)
+	}
+	for _, note := range footnotes {
+		buf.WriteString(gutter + " " + r.paint(ansiBlue, "=") + " " + r.paint(ansiBold, "note") + ": " + note + "\n")
+	}
+	return buf.String()
+}
+
+// writeSnippet 按行号顺序输出标签所在的源码行, 不相邻的行之间用 ... 分隔
+func (r *Renderer) writeSnippet(buf *strings.Builder, labels []label, width int) {
+	sort.SliceStable(labels, func(i, j int) bool {
+		if labels[i].span.Start.Line != labels[j].span.Start.Line {
+			return labels[i].span.Start.Line < labels[j].span.Start.Line
+		}
+		return labels[i].span.Start.Column < labels[j].span.Start.Column
+	})
+	gutter := strings.Repeat(" ", width)
+	previous := 0
+	for _, l := range labels {
+		line := l.span.Start.Line
+		if line != previous {
+			if previous != 0 && line > previous+1 {
+				buf.WriteString(r.paint(ansiBlue, "...") + "\n")
+			}
+			number := fmt.Sprintf("%*d", width, line)
+			buf.WriteString(r.paint(ansiBlue, number+" |") + " " + r.lines[line-1] + "\n")
+			previous = line
+		}
+		buf.WriteString(gutter + " " + r.paint(ansiBlue, "|") + " " + r.underline(l) + "\n")
+	}
+}
+
+// underline 返回标签的下划线. 下划线之前的部分保留源码中的制表符, 使下划线和源码对齐.
+// 跨越多行的区间只标记第一行
+func (r *Renderer) underline(l label) string {
+	text := r.lines[l.span.Start.Line-1]
+	start := min(max(l.span.Start.Column-1, 0), len(text))
+	end := len(text)
+	if l.span.End.Line == l.span.Start.Line {
+		end = min(max(l.span.End.Column-1, start), len(text))
+	}
+	var prefix strings.Builder
+	for _, ch := range text[:start] {
+		if ch == '\t' {
+			prefix.WriteRune('\t')
+		} else {
+			prefix.WriteRune(' ')
+		}
+	}
+	// 区间为空或位于行尾时至少标记一个字符
+	length := max(utf8.RuneCountInString(text[start:end]), 1)
+	mark, color := "-", ansiBlue
+	if l.primary {
+		mark, color = "^", ansiRed
+	}
+	s := strings.Repeat(mark, length)
+	if l.message != "" {
+		s += " " + l.message
+	}
+	return prefix.String() + r.paint(color, s)
+}
+
+// visible 判断区间是否位于源码中, 只有这样的区间才能输出源码片段
+func (r *Renderer) visible(span token.Span) bool {
+	return span.Start.IsValid() && span.Start.Line <= len(r.lines)
+}
+
+// paint 在彩色模式下用颜色包裹文本
+func (r *Renderer) paint(color, s string) string {
+	if !r.color {
+		return s
+	}
+	return color + s + ansiReset
+}
@@ -0,0 +1,105 @@
+package diagnostics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRenderer_Format(t *testing.T) {
+	source := "let a = 1\n\tlet b = a + \"s\"\nlet c = 3\nstruct P { x }\nstruct P { y }"
+	tests := []struct {
+		name string
+		d    *Diagnostic
+		want string
+	}{
+		{
+			name: "primary_span",
+			d:    Errorf(span(19, 2, 10, 7), InvalidOperation, "cannot add int and string"),
+			want: `error[invalid-operation]: cannot add int and string
+ --> main.tun:2:10
+  |
+2 | 	let b = a + "s"
+  | 	        ^^^^^^^
+`,
+		},
+		{
+			name: "note_on_earlier_line",
+			d: Errorf(span(51, 5, 1, 14), Redeclared, "struct P redeclared").
+				WithNote(span(36, 4, 1, 14), "first declared here"),
+			want: `error[redeclared]: struct P redeclared
+ --> main.tun:5:1
+  |
+4 | struct P { x }
+  | -------------- first declared here
+5 | struct P { y }
+  | ^^^^^^^^^^^^^^
+`,
+		},
+		{
+			name: "lines_are_not_adjacent",
+			d: Errorf(span(30, 3, 5, 1), Redeclared, "c redeclared").
+				WithNote(span(4, 1, 5, 1), "first declared here"),
+			want: `error[redeclared]: c redeclared
+ --> main.tun:3:5
+  |
+1 | let a = 1
+  |     - first declared here
+...
+3 | let c = 3
+  |     ^
+`,
+		},
+		{
+			name: "empty_span",
+			d:    Errorf(span(9, 1, 10, 0), SyntaxError, "expected expression"),
+			want: `error[syntax]: expected expression
+ --> main.tun:1:10
+  |
+1 | let a = 1
+  |          ^
+`,
+		},
+		{
+			name: "without_position",
+			d:    FromError(errors.New("failed to open main.tun"))[0],
+			want: "error: failed to open main.tun\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRenderer(source).Format(tt.d); got != tt.want {
+				t.Errorf("Format() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderer_Color(t *testing.T) {
+	d := Errorf(span(4, 1, 5, 1), Undefined, "undefined variable: a")
+	plain := NewRenderer("let a = 1").Format(d)
+	if strings.Contains(plain, "\x1b[") {
+		t.Errorf("Format() without color contains escape sequences: %q", plain)
+	}
+	colored := NewRenderer("let a = 1", WithColor()).Format(d)
+	if !strings.Contains(colored, ansiRed+"error[undefined]"+ansiReset) || !strings.Contains(colored, ansiRed+"^"+ansiReset) {
+		t.Errorf("Format() with color = %q, want red title and underline", colored)
+	}
+}
+
+func TestRenderer_Render(t *testing.T) {
+	var buf strings.Builder
+	list := List{
+		Errorf(span(0, 1, 1, 3), SyntaxError, "first"),
+		Errorf(span(4, 1, 5, 1), SyntaxError, "second"),
+	}
+	if err := NewRenderer("let a = 1").Render(&buf, list); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got := strings.Count(buf.String(), "error[syntax]"); got != 2 {
+		t.Errorf("Render() rendered %d diagnostics, want 2", got)
+	}
+	if !strings.Contains(buf.String(), "^^^\n\nerror[syntax]: second") {
+		t.Errorf("Render() = %q, want diagnostics separated by a blank line", buf.String())
+	}
+}
@@ -0,0 +1,96 @@
+package interpreter
+
+import (
+	"math"
+
+	"github.com/bootun/mini-tun/pkg/ast"
+)
+
+// Builtin 是由解释器实现的内置函数
+type Builtin struct {
+	Name  string
+	Arity int
+	Fn    func(node ast.Node, args []interface{}) (interface{}, error)
+}
+
+func (b *Builtin) String() string {
+	return "builtin " + b.Name
+}
+
+// builtins 内置函数, 定义在全局作用域外层的作用域中, 可以被同名变量遮蔽
+var builtins = []*Builtin{
+	{Name: "int", Arity: 1, Fn: builtinInt},
+	{Name: "float", Arity: 1, Fn: builtinFloat},
+	{Name: "len", Arity: 1, Fn: builtinLen},
+	{Name: "has", Arity: 2, Fn: builtinHas},
+	{Name: "keys", Arity: 1, Fn: builtinKeys},
+}
+
+// newBuiltinEnvironment 创建保存内置函数的作用域
+func newBuiltinEnvironment() *Environment {
+	env := NewEnvironment(nil)
+	for _, b := range builtins {
+		env.Define(b.Name, b)
+	}
+	return env
+}
+
+// builtinInt 把数字转换为int, 浮点数会向零取整
+func builtinInt(node ast.Node, args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case int:
+		return v, nil
+	case float64:
+		if math.IsNaN(v) || v >= math.MaxInt64 || v < math.MinInt64 {
+			return nil, runtimeError(node, "cannot convert %s to int", formatValue(v))
+		}
+		return int(v), nil
+	}
+	return nil, runtimeError(node, "cannot convert %s to int", typeName(args[0]))
+}
+
+// builtinFloat 把数字转换为float
+func builtinFloat(node ast.Node, args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return nil, runtimeError(node, "cannot convert %s to float", typeName(args[0]))
+}
+
+// builtinLen 返回数组的元素个数、字典的键值对个数或字符串的字节数
+func builtinLen(node ast.Node, args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case *Array:
+		return len(v.Elements), nil
+	case *Map:
+		return v.Len(), nil
+	case string:
+		return len(v), nil
+	}
+	return nil, runtimeError(node, "invalid argument for len: %s", typeName(args[0]))
+}
+
+// builtinHas 判断字典中是否存在键
+func builtinHas(node ast.Node, args []interface{}) (interface{}, error) {
+	m, ok := args[0].(*Map)
+	if !ok {
+		return nil, runtimeError(node, "invalid argument for has: %s", typeName(args[0]))
+	}
+	if err := checkMapKey(node, args[1]); err != nil {
+		return nil, err
+	}
+	_, ok = m.Get(args[1])
+	return ok, nil
+}
+
+// builtinKeys 按插入顺序返回字典的所有键
+func builtinKeys(node ast.Node, args []interface{}) (interface{}, error) {
+	m, ok := args[0].(*Map)
+	if !ok {
+		return nil, runtimeError(node, "invalid argument for keys: %s", typeName(args[0]))
+	}
+	return &Array{Elements: m.Keys()}, nil
+}
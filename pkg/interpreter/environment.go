@@ -0,0 +1,41 @@
+package interpreter
+
+// Environment 表示一个作用域, 保存该作用域中声明的变量,
+// 查找变量时会沿着parent向外层作用域查找
+type Environment struct {
+	store  map[string]interface{}
+	parent *Environment
+}
+
+func NewEnvironment(parent *Environment) *Environment {
+	return &Environment{
+		store:  make(map[string]interface{}),
+		parent: parent,
+	}
+}
+
+// Get 从当前作用域开始由内向外查找变量
+func (e *Environment) Get(name string) (interface{}, bool) {
+	for env := e; env != nil; env = env.parent {
+		if value, ok := env.store[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Define 在当前作用域中声明变量, 会遮蔽外层作用域的同名变量
+func (e *Environment) Define(name string, value interface{}) {
+	e.store[name] = value
+}
+
+// Assign 修改变量在其声明的作用域中的值, 变量未声明时返回false
+func (e *Environment) Assign(name string, value interface{}) bool {
+	for env := e; env != nil; env = env.parent {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = value
+			return true
+		}
+	}
+	return false
+}
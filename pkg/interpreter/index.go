@@ -0,0 +1,110 @@
+package interpreter
+
+import (
+	"github.com/bootun/mini-tun/pkg/ast"
+)
+
+// computeIndexOperands 计算下标表达式中被取下标的值和下标
+func (s *functionStack) computeIndexOperands(node *ast.IndexExpression) (interface{}, interface{}, error) {
+	left, err := s.computeExpression(node.Left)
+	if err != nil {
+		return nil, nil, err
+	}
+	index, err := s.computeExpression(node.Index)
+	if err != nil {
+		return nil, nil, err
+	}
+	return left, index, nil
+}
+
+// indexValue 返回数组中下标为index的元素或字典中键为index的值
+func indexValue(node *ast.IndexExpression, left, index interface{}) (interface{}, error) {
+	switch container := left.(type) {
+	case *Array:
+		i, err := arrayIndex(node, container, index)
+		if err != nil {
+			return nil, err
+		}
+		return container.Elements[i], nil
+	case *Map:
+		if err := checkMapKey(node.Index, index); err != nil {
+			return nil, err
+		}
+		value, ok := container.Get(index)
+		if !ok {
+			return nil, runtimeError(node.Index, "key not found: %s", inspectValue(index))
+		}
+		return value, nil
+	}
+	return nil, runtimeError(node, "cannot index %s", typeName(left))
+}
+
+// setIndex 修改数组中下标为index的元素, 或者设置字典中键为index的值
+func setIndex(node *ast.IndexExpression, left, index, value interface{}) error {
+	switch container := left.(type) {
+	case *Array:
+		i, err := arrayIndex(node, container, index)
+		if err != nil {
+			return err
+		}
+		container.Elements[i] = value
+		return nil
+	case *Map:
+		if err := checkMapKey(node.Index, index); err != nil {
+			return err
+		}
+		container.Set(index, value)
+		return nil
+	}
+	return runtimeError(node, "cannot index %s", typeName(left))
+}
+
+// arrayIndex 检查数组下标的类型以及是否越界
+func arrayIndex(node *ast.IndexExpression, array *Array, index interface{}) (int, error) {
+	i, ok := index.(int)
+	if !ok {
+		return 0, runtimeError(node.Index, "expected int, but got %s", typeName(index))
+	}
+	if i < 0 || i >= len(array.Elements) {
+		return 0, runtimeError(node.Index, "index out of range [%d] with length %d", i, len(array.Elements))
+	}
+	return i, nil
+}
+
+// computeSlice 计算切片表达式, 结果是一个新的数组, 修改它不会影响原数组
+func (s *functionStack) computeSlice(node *ast.SliceExpression) (interface{}, error) {
+	left, err := s.computeExpression(node.Left)
+	if err != nil {
+		return nil, err
+	}
+	array, ok := left.(*Array)
+	if !ok {
+		return nil, runtimeError(node, "cannot slice %s", typeName(left))
+	}
+	low, high := 0, len(array.Elements)
+	if node.Low != nil {
+		if low, err = s.computeInt(node.Low); err != nil {
+			return nil, err
+		}
+	}
+	if node.High != nil {
+		if high, err = s.computeInt(node.High); err != nil {
+			return nil, err
+		}
+	}
+	if low < 0 || high > len(array.Elements) || low > high {
+		return nil, runtimeError(node, "slice bounds out of range [%d:%d] with length %d", low, high, len(array.Elements))
+	}
+	elements := make([]interface{}, high-low)
+	copy(elements, array.Elements[low:high])
+	return &Array{Elements: elements}, nil
+}
+
+// checkMapKey 检查值是否可以作为字典的键, 只有int和string可以作为键
+func checkMapKey(node ast.Node, key interface{}) error {
+	switch key.(type) {
+	case int, string:
+		return nil
+	}
+	return runtimeError(node, "invalid map key type %s", typeName(key))
+}
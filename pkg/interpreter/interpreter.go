@@ -2,9 +2,10 @@ package interpreter
 
 import (
 	"fmt"
-	"maps"
+	"sort"
 
 	"github.com/bootun/mini-tun/pkg/ast"
+	"github.com/bootun/mini-tun/pkg/diagnostics"
 	"github.com/bootun/mini-tun/pkg/token"
 )
 
@@ -14,94 +15,272 @@ type Interpreter struct {
 }
 
 func NewInterpreter(program ast.Program) *Interpreter {
+	globals := NewEnvironment(newBuiltinEnvironment())
 	return &Interpreter{
 		stack: functionStack{
-			envs: make(map[string]interface{}),
+			env:     globals,
+			globals: globals,
+			structs: make(map[string]*StructType),
 		},
 		program: program,
 	}
 }
 
 func (i *Interpreter) Exec() error {
+	// 执行第一条语句之前声明结构体和最外层的具名函数
+	i.stack.declareStructs(i.program.Statements)
+	i.stack.hoistDeclarations(i.program.Statements)
 	for _, statement := range i.program.Statements {
-		switch statement.(type) {
-		case *ast.VariableAssignment:
-			variableAssignment := statement.(*ast.VariableAssignment)
-			// 判断value是函数还是值
-			i.stack.envs[variableAssignment.VariableName] = i.stack.computeExpression(variableAssignment.Value)
+		result, err := i.stack.execStatement(statement)
+		if err != nil {
+			return err
+		}
+		// 顶层的return会结束程序
+		if result.control == controlReturn {
+			break
 		}
 	}
 	// 运行最终态
-	for k, v := range i.stack.envs {
+	names := make([]string, 0, len(i.stack.globals.store))
+	for name := range i.stack.globals.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		v := i.stack.globals.store[k]
 		switch v.(type) {
 		case int:
 			fmt.Printf("%s = %d\n", k, v.(int))
-		case *ast.FunctionLiteral:
-			fmt.Printf("%s = %s\n", k, v.(*ast.FunctionLiteral).TokenLiteral())
+		case float64:
+			fmt.Printf("%s = %s\n", k, formatValue(v))
+		case bool:
+			fmt.Printf("%s = %t\n", k, v.(bool))
+		case string:
+			fmt.Printf("%s = %q\n", k, v.(string))
+		case *Closure:
+			fmt.Printf("%s = %s\n", k, v.(*Closure))
+		case *Array:
+			fmt.Printf("%s = %s\n", k, v.(*Array))
+		case *Map:
+			fmt.Printf("%s = %s\n", k, v.(*Map))
+		case *StructValue:
+			fmt.Printf("%s = %s\n", k, v.(*StructValue))
 		}
 	}
 	return nil
 }
 
-func (s *functionStack) computeExpression(expression ast.Expression) interface{} {
+// runtimeError 返回一个位于节点处的运行时错误
+func runtimeError(node ast.Node, format string, args ...interface{}) error {
+	return diagnostics.Errorf(node.GetSpan(), diagnostics.RuntimeError, format, args...)
+}
+
+func (s *functionStack) computeExpression(expression ast.Expression) (interface{}, error) {
 	switch expression.(type) {
 	case *ast.LiteralExpression:
-		return expression.(*ast.LiteralExpression).Value
+		return expression.(*ast.LiteralExpression).Value, nil
+	case *ast.FloatLiteral:
+		return expression.(*ast.FloatLiteral).Value, nil
+	case *ast.BooleanLiteral:
+		return expression.(*ast.BooleanLiteral).Value, nil
+	case *ast.StringLiteral:
+		return expression.(*ast.StringLiteral).Value, nil
 	case *ast.ComplexExpression:
 		node := expression.(*ast.ComplexExpression)
-		left := s.computeExpression(node.Left).(int)
-		right := s.computeExpression(node.Right).(int)
-		switch expression.(*ast.ComplexExpression).Operator.Type {
-		case token.PLUS:
-			return left + right
-		case token.MINUS:
-			return left - right
-		default:
-			panic("unsupported operator")
+		left, err := s.computeExpression(node.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := s.computeExpression(node.Right)
+		if err != nil {
+			return nil, err
 		}
+		return computeBinary(node, node.Operator, left, right)
+	case *ast.LogicalExpression:
+		node := expression.(*ast.LogicalExpression)
+		left, err := s.computeBool(node.Left)
+		if err != nil {
+			return nil, err
+		}
+		// 短路求值
+		if node.Operator.Type == token.AND && !left {
+			return false, nil
+		}
+		if node.Operator.Type == token.OR && left {
+			return true, nil
+		}
+		return s.computeBool(node.Right)
+	case *ast.PrefixExpression:
+		node := expression.(*ast.PrefixExpression)
+		right, err := s.computeExpression(node.Right)
+		if err != nil {
+			return nil, err
+		}
+		return computePrefix(node, right)
 	case *ast.IdentifierExpression:
 		node := expression.(*ast.IdentifierExpression)
-		return s.envs[node.Value].(int)
+		value, ok := s.env.Get(node.Value)
+		if !ok {
+			return nil, runtimeError(node, "undefined variable: %s", node.Value)
+		}
+		return value, nil
 	case *ast.FunctionCall:
 		// 函数调用
-		node := expression.(*ast.FunctionCall)
-		funcDecl := s.envs[node.FunctionName].(*ast.FunctionLiteral)
-		params := make(map[string]interface{})
-		for i, param := range node.Arguments {
-			name := funcDecl.Parameters[i].Value
-			value := s.computeExpression(param).(int)
-			params[name] = value
-		}
-		// 初始化函数栈
-		envs := maps.Clone(s.envs)
-		callStack := functionStack{envs: envs}
-		return callStack.computeFunction(funcDecl)
+		return s.computeCall(expression.(*ast.FunctionCall))
 
 	case *ast.FunctionLiteral:
 		// 函数定义
 		node := expression.(*ast.FunctionLiteral)
-		return node
+		return &Closure{Function: node, Env: s.env}, nil
+	case *ast.ArrayLiteral:
+		node := expression.(*ast.ArrayLiteral)
+		elements := make([]interface{}, 0, len(node.Elements))
+		for _, element := range node.Elements {
+			value, err := s.computeExpression(element)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, value)
+		}
+		return &Array{Elements: elements}, nil
+	case *ast.IndexExpression:
+		node := expression.(*ast.IndexExpression)
+		left, index, err := s.computeIndexOperands(node)
+		if err != nil {
+			return nil, err
+		}
+		return indexValue(node, left, index)
+	case *ast.SliceExpression:
+		return s.computeSlice(expression.(*ast.SliceExpression))
+	case *ast.MapLiteral:
+		node := expression.(*ast.MapLiteral)
+		m := NewMap()
+		for _, pair := range node.Pairs {
+			key, err := s.computeExpression(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkMapKey(pair.Key, key); err != nil {
+				return nil, err
+			}
+			value, err := s.computeExpression(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(key, value)
+		}
+		return m, nil
+	case *ast.StructLiteral:
+		return s.computeStructLiteral(expression.(*ast.StructLiteral))
+	case *ast.FieldAccess:
+		value, i, err := s.computeField(expression.(*ast.FieldAccess))
+		if err != nil {
+			return nil, err
+		}
+		return value.Fields[i], nil
 	}
-	return 0
+	return 0, nil
 }
 
+// computeCall 依次计算被调用的函数和参数, 然后调用函数
+func (s *functionStack) computeCall(node *ast.FunctionCall) (interface{}, error) {
+	callee, err := s.computeExpression(node.Callee)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, len(node.Arguments))
+	for _, arg := range node.Arguments {
+		value, err := s.computeExpression(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	switch function := callee.(type) {
+	case *Builtin:
+		if err := checkArity(node, function.Name, function.Arity, len(args)); err != nil {
+			return nil, err
+		}
+		return function.Fn(node, args)
+	case *Closure:
+		name := "function"
+		if identifier, ok := node.Callee.(*ast.IdentifierExpression); ok {
+			name = identifier.Value
+		}
+		if err := checkArity(node, name, len(function.Function.Parameters), len(args)); err != nil {
+			return nil, err
+		}
+		// 初始化函数栈, 参数绑定在函数自己的作用域中, 函数体中的其他名字在函数定义时的作用域中查找
+		callStack := functionStack{
+			env:     NewEnvironment(function.Env),
+			globals: s.globals,
+			structs: s.structs,
+		}
+		for i, value := range args {
+			callStack.env.Define(function.Function.Parameters[i].Value, value)
+		}
+		return callStack.computeFunction(function.Function)
+	}
+	return nil, runtimeError(node.Callee, "value is not callable: %s is %s", node.Callee.TokenLiteral(), typeName(callee))
+}
+
+// checkArity 检查调用时传入的参数个数是否和函数的参数个数一致
+func checkArity(node *ast.FunctionCall, name string, want, got int) error {
+	switch {
+	case got < want:
+		return runtimeError(node, "not enough arguments in call to %s: expected %d, but got %d", name, want, got)
+	case got > want:
+		return runtimeError(node, "too many arguments in call to %s: expected %d, but got %d", name, want, got)
+	}
+	return nil
+}
+
+// computeInt 计算表达式, 并要求结果为int
+func (s *functionStack) computeInt(expression ast.Expression) (int, error) {
+	value, err := s.computeExpression(expression)
+	if err != nil {
+		return 0, err
+	}
+	v, ok := value.(int)
+	if !ok {
+		return 0, runtimeError(expression, "expected int, but got %s", typeName(value))
+	}
+	return v, nil
+}
+
+// computeBool 计算表达式, 并要求结果为bool
+func (s *functionStack) computeBool(expression ast.Expression) (bool, error) {
+	value, err := s.computeExpression(expression)
+	if err != nil {
+		return false, err
+	}
+	v, ok := value.(bool)
+	if !ok {
+		return false, runtimeError(expression, "expected bool, but got %s", typeName(value))
+	}
+	return v, nil
+}
+
+// functionStack 是一次函数调用的执行上下文
 type functionStack struct {
-	envs map[string]interface{}
+	env     *Environment           // 当前作用域
+	globals *Environment           // 全局作用域
+	structs map[string]*StructType // 程序中声明的结构体, 和变量在不同的命名空间中
 }
 
-func (s *functionStack) computeFunction(function *ast.FunctionLiteral) interface{} {
+func (s *functionStack) computeFunction(function *ast.FunctionLiteral) (interface{}, error) {
 	if function.Body == nil {
-		return nil
+		return nil, nil
 	}
+	s.hoistDeclarations(function.Body.Statements)
 	for _, statement := range function.Body.Statements {
-		switch statement.(type) {
-		case *ast.VariableAssignment:
-			variableAssignment := statement.(*ast.VariableAssignment)
-			// 判断value是函数还是值
-			s.envs[variableAssignment.VariableName] = s.computeExpression(variableAssignment.Value)
-		case *ast.ReturnStatement:
-			return s.computeExpression(statement.(*ast.ReturnStatement).ReturnValue)
+		result, err := s.execStatement(statement)
+		if err != nil {
+			return nil, err
+		}
+		if result.control == controlReturn {
+			return result.value, nil
 		}
 	}
-	return nil
+	return nil, nil
 }
@@ -0,0 +1,467 @@
+package interpreter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bootun/mini-tun/pkg/lexer"
+	"github.com/bootun/mini-tun/pkg/parser"
+)
+
+func TestInterpreter_Exec(t *testing.T) {
+	type fields struct {
+		input string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		want    map[string]interface{} // 执行结束后全局变量的值
+		wantErr bool
+	}{
+		{
+			name: "arguments_bind_to_parameters",
+			fields: fields{
+				`
+let a = 3
+let b = 2
+let c = a - b
+let add = function (a, b) {
+    let c = a + b
+    return a + c
+}
+let d = add(a, add(b, c))`,
+			},
+			want:    map[string]interface{}{"a": 3, "b": 2, "c": 1, "d": 11},
+			wantErr: false,
+		},
+		{
+			name: "parameters_shadow_globals",
+			fields: fields{
+				`
+let x = 100
+let id = function (x) {
+    return x
+}
+let y = id(1)`,
+			},
+			want:    map[string]interface{}{"x": 100, "y": 1},
+			wantErr: false,
+		},
+		{
+			name: "block_scope",
+			fields: fields{
+				`
+let x = 1
+let total = 0
+if x > 0 {
+    let x = 2
+    total = total + x
+}
+for let i = 0; i < 3; i = i + 1 {
+    total += i
+}
+total += x`,
+			},
+			want:    map[string]interface{}{"x": 1, "total": 6},
+			wantErr: false,
+		},
+		{
+			name: "while_loop",
+			fields: fields{
+				`
+let n = 5
+let fact = 1
+while n > 1 {
+    fact *= n
+    n -= 1
+}`,
+			},
+			want:    map[string]interface{}{"n": 1, "fact": 120},
+			wantErr: false,
+		},
+		{
+			name: "break_leaves_inner_loop",
+			fields: fields{
+				`
+let pairs = 0
+let last = 0
+for let i = 0; i < 3; i += 1 {
+    for let j = 0; j < 10; j += 1 {
+        if j == 2 {
+            break
+        }
+        pairs += 1
+        last = i * 10 + j
+    }
+}`,
+			},
+			want:    map[string]interface{}{"pairs": 6, "last": 21},
+			wantErr: false,
+		},
+		{
+			name: "continue_skips_iteration",
+			fields: fields{
+				`
+let sum = 0
+let i = 0
+while i < 6 {
+    i += 1
+    if i % 2 == 0 {
+        continue
+    }
+    sum += i
+}`,
+			},
+			want:    map[string]interface{}{"sum": 9, "i": 6},
+			wantErr: false,
+		},
+		{
+			name: "closure_captures_environment",
+			fields: fields{
+				`
+let makeCounter = function() {
+    let n = 0
+    return function() {
+        n += 1
+        return n
+    }
+}
+let counter = makeCounter()
+let other = makeCounter()
+counter()
+counter()
+let a = counter()
+let b = other()
+let makeAdder = function(x) {
+    return function(y) {
+        return x + y
+    }
+}
+let add5 = makeAdder(5)
+let c = add5(10)`,
+			},
+			want:    map[string]interface{}{"a": 3, "b": 1, "c": 15},
+			wantErr: false,
+		},
+		{
+			name: "recursive_function",
+			fields: fields{
+				`
+let fib = function(n) {
+    if n < 2 {
+        return n
+    }
+    return fib(n - 1) + fib(n - 2)
+}
+let a = fib(10)`,
+			},
+			want:    map[string]interface{}{"a": 55},
+			wantErr: false,
+		},
+		{
+			name: "hoisted_mutually_recursive_functions",
+			fields: fields{
+				`
+let a = isEven(10)
+let b = isOdd(7)
+function isEven(n) {
+    if n == 0 {
+        return true
+    }
+    return isOdd(n - 1)
+}
+function isOdd(n) {
+    if n == 0 {
+        return false
+    }
+    return isEven(n - 1)
+}`,
+			},
+			want:    map[string]interface{}{"a": true, "b": true},
+			wantErr: false,
+		},
+		{
+			name: "logical_operators_short_circuit",
+			fields: fields{
+				`
+let calls = 0
+let f = function() {
+    calls += 1
+    return true
+}
+let a = false && f()
+let b = true || f()
+let c = true && f()`,
+			},
+			want:    map[string]interface{}{"a": false, "b": true, "c": true, "calls": 1},
+			wantErr: false,
+		},
+		{
+			name: "mix_int_and_bool",
+			fields: fields{
+				`let a = 1 && true`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "strings",
+			fields: fields{
+				`
+let name = "tun"
+let n = 2
+let a = "hello, " + name
+let b = "${name} has ${n + 1} parts, ok=${n > 1}\n"
+let c = "abc" < "abd" && a != b`,
+			},
+			want: map[string]interface{}{
+				"a": "hello, tun",
+				"b": "tun has 3 parts, ok=true\n",
+				"c": true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "float_arithmetic",
+			fields: fields{
+				`
+let a = 7 / 2
+let b = 1 + 0.5
+let c = int(7 / 2)
+let d = float(3) * 2
+let e = 0b101 % 0o3
+let f = 1 < 1.5
+let s = "x=${b}, y=${d}"`,
+			},
+			want:    map[string]interface{}{"a": 3.5, "b": 1.5, "c": 3, "d": 6.0, "e": 2, "f": true, "s": "x=1.5, y=6.0"},
+			wantErr: false,
+		},
+		{
+			name: "float_division_by_zero",
+			fields: fields{
+				`let a = 1.5 / 0`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "array_operations",
+			fields: fields{
+				`
+let a = [1, 2, 3]
+let alias = a
+a[0] = 10
+a[1] *= 5
+let tail = a[1:]
+tail[0] = 0
+let n = len(a[:2])
+let total = 0
+for let i = 0; i < len(a); i += 1 {
+    total += a[i]
+}`,
+			},
+			want: map[string]interface{}{
+				"alias": &Array{Elements: []interface{}{10, 10, 3}},
+				"tail":  &Array{Elements: []interface{}{0, 3}},
+				"n":     2,
+				"total": 23,
+			},
+			wantErr: false,
+		},
+		{
+			name: "index_out_of_range",
+			fields: fields{
+				`
+let a = [1, 2, 3]
+let b = a[3]`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "slice_out_of_range",
+			fields: fields{
+				`
+let a = [1, 2, 3]
+let b = a[2:1]`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "map_operations",
+			fields: fields{
+				`
+let m = {"b": 1, 2: 20}
+m["a"] = 10
+m["b"] += 5
+let hasA = has(m, "a")
+let hasC = has(m, "c")
+let order = keys(m)
+let n = len(m)`,
+			},
+			want: map[string]interface{}{
+				"hasA":  true,
+				"hasC":  false,
+				"order": &Array{Elements: []interface{}{"b", 2, "a"}},
+				"n":     3,
+			},
+			wantErr: false,
+		},
+		{
+			name: "map_key_not_found",
+			fields: fields{
+				`
+let m = {"a": 1}
+let b = m["b"]`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "struct_operations",
+			fields: fields{
+				`
+let p = Point{y: 2, x: 1}
+let q = p
+q.x += 10
+let line = Line{from: p, to: Point{x: 0, y: 0}}
+line.to.y = p.x
+let y = line.to.y
+struct Point { x, y }
+struct Line { from, to }`,
+			},
+			want: map[string]interface{}{
+				"y": 11,
+			},
+			wantErr: false,
+		},
+		{
+			name: "struct_shadowed_by_variable",
+			fields: fields{
+				`
+struct P { x }
+let P = 1
+let make = function(P) {
+	return P{x: P}
+}
+let a = make(2).x + P`,
+			},
+			want:    map[string]interface{}{"a": 3},
+			wantErr: false,
+		},
+		{
+			name: "unknown_field",
+			fields: fields{
+				`
+struct Point { x, y }
+let p = Point{x: 1, y: 2}
+let z = p.z`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "call_arbitrary_callee",
+			fields: fields{
+				`
+let makeAdder = function(x) {
+    return function(y) {
+        return x + y
+    }
+}
+let a = makeAdder(1)(2)
+let b = (function(x) { return x * 2 })(3)
+let ops = [makeAdder(10), function(v) { return -v }]
+let c = ops[0](1) + ops[1](1)
+struct Box { f }
+let d = Box{f: makeAdder}.f(4)(5)`,
+			},
+			want:    map[string]interface{}{"a": 3, "b": 6, "c": 10, "d": 9},
+			wantErr: false,
+		},
+		{
+			name: "generic_function",
+			fields: fields{
+				`
+function first<A, B>(a: A, b: B): A { return a }
+let id = function(x) { return x }
+let a = first(1, "x")
+let b = first("y", 2)
+let c = id(3) + first(4, true)`,
+			},
+			want:    map[string]interface{}{"a": 1, "b": "y", "c": 7},
+			wantErr: false,
+		},
+		{
+			name: "value_is_not_callable",
+			fields: fields{
+				`
+let a = 1
+let b = a(2)`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "too_many_arguments",
+			fields: fields{
+				`
+let id = function(x) { return x }
+let f = id
+let a = f(1, 2)`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "not_enough_arguments",
+			fields: fields{
+				`
+let add = function(a, b) { return a + b }
+let a = [add][0](1)`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid_string_operation",
+			fields: fields{
+				`
+let s = "a"
+let t = s - 1`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "undefined_variable",
+			fields: fields{
+				`let a = b + 1`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := parser.New(lexer.New(tt.fields.input)).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			i := NewInterpreter(program)
+			err = i.Exec()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Exec() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			got := globalValues(i, tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Exec() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// globalValues 取出want中列出的全局变量的值
+func globalValues(i *Interpreter, want map[string]interface{}) map[string]interface{} {
+	got := make(map[string]interface{})
+	for name := range want {
+		if value, ok := i.stack.globals.Get(name); ok {
+			got[name] = value
+		}
+	}
+	return got
+}
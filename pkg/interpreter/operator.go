@@ -0,0 +1,195 @@
+package interpreter
+
+import (
+	"github.com/bootun/mini-tun/pkg/ast"
+	"github.com/bootun/mini-tun/pkg/token"
+)
+
+// typeName 返回运行时值的类型名称, 用于错误信息
+func typeName(value interface{}) string {
+	switch v := value.(type) {
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case *Closure, *Builtin:
+		return "function"
+	case *Array:
+		return "array"
+	case *Map:
+		return "map"
+	case *StructValue:
+		return v.Type.Name
+	case nil:
+		return "nil"
+	default:
+		return "unknown"
+	}
+}
+
+// computeBinary 计算二元运算, 两侧的值需要是运算符支持的类型, node 用于在错误信息中定位
+func computeBinary(node ast.Node, operator token.Token, left, right interface{}) (interface{}, error) {
+	switch l := left.(type) {
+	case int:
+		switch r := right.(type) {
+		case int:
+			return computeIntBinary(node, operator, l, r)
+		case float64:
+			return computeFloatBinary(node, operator, float64(l), r)
+		}
+	case float64:
+		switch r := right.(type) {
+		case int:
+			return computeFloatBinary(node, operator, l, float64(r))
+		case float64:
+			return computeFloatBinary(node, operator, l, r)
+		}
+	case bool:
+		if r, ok := right.(bool); ok {
+			switch operator.Type {
+			case token.EQ:
+				return l == r, nil
+			case token.NOT_EQ:
+				return l != r, nil
+			}
+		}
+	case string:
+		if r, ok := right.(string); ok {
+			return computeStringBinary(node, operator, l, r)
+		}
+	}
+	// 字符串与其他基本类型相加时, 先把另一侧转换为字符串再拼接
+	if operator.Type == token.PLUS && isPrimitive(left) && isPrimitive(right) {
+		_, leftIsString := left.(string)
+		_, rightIsString := right.(string)
+		if leftIsString || rightIsString {
+			return formatValue(left) + formatValue(right), nil
+		}
+	}
+	return nil, runtimeError(node, "invalid operation: %s %s %s", typeName(left), operator.Literal, typeName(right))
+}
+
+func computeIntBinary(node ast.Node, operator token.Token, left, right int) (interface{}, error) {
+	switch operator.Type {
+	case token.PLUS:
+		return left + right, nil
+	case token.MINUS:
+		return left - right, nil
+	case token.ASTERISK:
+		return left * right, nil
+	case token.SLASH:
+		// 整数相除的结果为float, 不会被截断, 需要整数结果时可以使用 int(a / b)
+		if right == 0 {
+			return nil, runtimeError(node, "division by zero")
+		}
+		return float64(left) / float64(right), nil
+	case token.PERCENT:
+		if right == 0 {
+			return nil, runtimeError(node, "division by zero")
+		}
+		return left % right, nil
+	case token.EQ:
+		return left == right, nil
+	case token.NOT_EQ:
+		return left != right, nil
+	case token.LT:
+		return left < right, nil
+	case token.LTE:
+		return left <= right, nil
+	case token.GT:
+		return left > right, nil
+	case token.GTE:
+		return left >= right, nil
+	default:
+		return nil, runtimeError(node, "unsupported operator: %s", operator.Literal)
+	}
+}
+
+// computeFloatBinary 计算浮点数的二元运算, int和float混合运算时int会先被转换为float
+func computeFloatBinary(node ast.Node, operator token.Token, left, right float64) (interface{}, error) {
+	switch operator.Type {
+	case token.PLUS:
+		return left + right, nil
+	case token.MINUS:
+		return left - right, nil
+	case token.ASTERISK:
+		return left * right, nil
+	case token.SLASH:
+		if right == 0 {
+			return nil, runtimeError(node, "division by zero")
+		}
+		return left / right, nil
+	case token.EQ:
+		return left == right, nil
+	case token.NOT_EQ:
+		return left != right, nil
+	case token.LT:
+		return left < right, nil
+	case token.LTE:
+		return left <= right, nil
+	case token.GT:
+		return left > right, nil
+	case token.GTE:
+		return left >= right, nil
+	default:
+		return nil, runtimeError(node, "invalid operation: float %s float", operator.Literal)
+	}
+}
+
+func computeStringBinary(node ast.Node, operator token.Token, left, right string) (interface{}, error) {
+	switch operator.Type {
+	case token.PLUS:
+		return left + right, nil
+	case token.EQ:
+		return left == right, nil
+	case token.NOT_EQ:
+		return left != right, nil
+	case token.LT:
+		return left < right, nil
+	case token.LTE:
+		return left <= right, nil
+	case token.GT:
+		return left > right, nil
+	case token.GTE:
+		return left >= right, nil
+	default:
+		return nil, runtimeError(node, "invalid operation: string %s string", operator.Literal)
+	}
+}
+
+// compoundOperators 复合赋值运算符对应的二元运算符
+var compoundOperators = map[token.TokenType]token.Token{
+	token.PLUS_ASSIGN:     token.New(token.PLUS, "+"),
+	token.MINUS_ASSIGN:    token.New(token.MINUS, "-"),
+	token.ASTERISK_ASSIGN: token.New(token.ASTERISK, "*"),
+	token.SLASH_ASSIGN:    token.New(token.SLASH, "/"),
+}
+
+// computePrefix 计算前缀运算
+func computePrefix(node *ast.PrefixExpression, right interface{}) (interface{}, error) {
+	switch node.Operator.Type {
+	case token.MINUS:
+		switch v := right.(type) {
+		case int:
+			return -v, nil
+		case float64:
+			return -v, nil
+		}
+	case token.PLUS:
+		switch v := right.(type) {
+		case int, float64:
+			return v, nil
+		}
+	case token.BANG:
+		if v, ok := right.(bool); ok {
+			return !v, nil
+		}
+	default:
+		return nil, runtimeError(node, "unsupported operator: %s", node.Operator.Literal)
+	}
+	return nil, runtimeError(node, "invalid operation: %s%s", node.Operator.Literal, typeName(right))
+}
@@ -0,0 +1,225 @@
+package interpreter
+
+import (
+	"github.com/bootun/mini-tun/pkg/ast"
+)
+
+// control 表示语句执行后的控制流走向
+type control int
+
+const (
+	controlNormal   control = iota // 继续执行下一条语句
+	controlReturn                  // 从当前函数返回
+	controlBreak                   // 跳出当前循环
+	controlContinue                // 进入当前循环的下一次迭代
+)
+
+// execResult 是语句的执行结果, value 只在 controlReturn 时有意义
+type execResult struct {
+	control control
+	value   interface{}
+}
+
+var normalResult = execResult{control: controlNormal}
+
+func (s *functionStack) execStatement(statement ast.Statement) (execResult, error) {
+	switch statement.(type) {
+	case *ast.VariableAssignment:
+		variableAssignment := statement.(*ast.VariableAssignment)
+		// 判断value是函数还是值
+		value, err := s.computeExpression(variableAssignment.Value)
+		if err != nil {
+			return normalResult, err
+		}
+		s.env.Define(variableAssignment.VariableName, value)
+		return normalResult, nil
+	case *ast.FunctionDeclaration, *ast.StructDecl:
+		// 具名函数和结构体已经在语句块开始执行前提升
+		return normalResult, nil
+	case *ast.Assignment:
+		node := statement.(*ast.Assignment)
+		current, ok := s.env.Get(node.VariableName)
+		if !ok {
+			return normalResult, runtimeError(node, "cannot assign to undeclared variable: %s", node.VariableName)
+		}
+		value, err := s.computeExpression(node.Value)
+		if err != nil {
+			return normalResult, err
+		}
+		if operator, ok := compoundOperators[node.Operator.Type]; ok {
+			value, err = computeBinary(node, operator, current, value)
+			if err != nil {
+				return normalResult, err
+			}
+		}
+		s.env.Assign(node.VariableName, value)
+		return normalResult, nil
+	case *ast.IndexAssignment:
+		node := statement.(*ast.IndexAssignment)
+		left, index, err := s.computeIndexOperands(node.Target)
+		if err != nil {
+			return normalResult, err
+		}
+		value, err := s.computeExpression(node.Value)
+		if err != nil {
+			return normalResult, err
+		}
+		if operator, ok := compoundOperators[node.Operator.Type]; ok {
+			current, err := indexValue(node.Target, left, index)
+			if err != nil {
+				return normalResult, err
+			}
+			value, err = computeBinary(node, operator, current, value)
+			if err != nil {
+				return normalResult, err
+			}
+		}
+		if err := setIndex(node.Target, left, index, value); err != nil {
+			return normalResult, err
+		}
+		return normalResult, nil
+	case *ast.FieldAssignment:
+		node := statement.(*ast.FieldAssignment)
+		target, i, err := s.computeField(node.Target)
+		if err != nil {
+			return normalResult, err
+		}
+		value, err := s.computeExpression(node.Value)
+		if err != nil {
+			return normalResult, err
+		}
+		if operator, ok := compoundOperators[node.Operator.Type]; ok {
+			value, err = computeBinary(node, operator, target.Fields[i], value)
+			if err != nil {
+				return normalResult, err
+			}
+		}
+		target.Fields[i] = value
+		return normalResult, nil
+	case *ast.ExpressionStatement:
+		if _, err := s.computeExpression(statement.(*ast.ExpressionStatement).Expression); err != nil {
+			return normalResult, err
+		}
+		return normalResult, nil
+	case *ast.ReturnStatement:
+		value, err := s.computeExpression(statement.(*ast.ReturnStatement).ReturnValue)
+		if err != nil {
+			return normalResult, err
+		}
+		return execResult{control: controlReturn, value: value}, nil
+	case *ast.BlockStatement:
+		return s.execBlock(statement.(*ast.BlockStatement))
+	case *ast.IfStatement:
+		node := statement.(*ast.IfStatement)
+		condition, err := s.computeBool(node.Condition)
+		if err != nil {
+			return normalResult, err
+		}
+		if condition {
+			return s.execBlock(node.Consequence)
+		}
+		if node.Alternative != nil {
+			return s.execStatement(node.Alternative)
+		}
+		return normalResult, nil
+	case *ast.WhileStatement:
+		node := statement.(*ast.WhileStatement)
+		for {
+			condition, err := s.computeBool(node.Condition)
+			if err != nil {
+				return normalResult, err
+			}
+			if !condition {
+				return normalResult, nil
+			}
+			result, err := s.execBlock(node.Body)
+			if err != nil {
+				return normalResult, err
+			}
+			if result.control == controlBreak {
+				return normalResult, nil
+			}
+			if result.control == controlReturn {
+				return result, nil
+			}
+		}
+	case *ast.ForStatement:
+		return s.execFor(statement.(*ast.ForStatement))
+	case *ast.BreakStatement:
+		return execResult{control: controlBreak}, nil
+	case *ast.ContinueStatement:
+		return execResult{control: controlContinue}, nil
+	default:
+		return normalResult, runtimeError(statement, "unsupported statement: %s", statement.TokenLiteral())
+	}
+}
+
+// execBlock 在新的作用域中依次执行语句块中的语句, 遇到return、break、continue时立即停止并将其向上传递
+func (s *functionStack) execBlock(block *ast.BlockStatement) (execResult, error) {
+	defer s.enterScope()()
+	s.hoistDeclarations(block.Statements)
+	for _, statement := range block.Statements {
+		result, err := s.execStatement(statement)
+		if err != nil {
+			return normalResult, err
+		}
+		if result.control != controlNormal {
+			return result, nil
+		}
+	}
+	return normalResult, nil
+}
+
+func (s *functionStack) execFor(node *ast.ForStatement) (execResult, error) {
+	// 初始化语句声明的变量只在循环内可见
+	defer s.enterScope()()
+	if node.Init != nil {
+		if _, err := s.execStatement(node.Init); err != nil {
+			return normalResult, err
+		}
+	}
+	for {
+		if node.Condition != nil {
+			condition, err := s.computeBool(node.Condition)
+			if err != nil {
+				return normalResult, err
+			}
+			if !condition {
+				return normalResult, nil
+			}
+		}
+		result, err := s.execBlock(node.Body)
+		if err != nil {
+			return normalResult, err
+		}
+		if result.control == controlBreak {
+			return normalResult, nil
+		}
+		if result.control == controlReturn {
+			return result, nil
+		}
+		if node.Post != nil {
+			if _, err := s.execStatement(node.Post); err != nil {
+				return normalResult, err
+			}
+		}
+	}
+}
+
+// enterScope 进入一个新的块作用域, 返回用于退出该作用域的函数
+func (s *functionStack) enterScope() func() {
+	outer := s.env
+	s.env = NewEnvironment(outer)
+	return func() {
+		s.env = outer
+	}
+}
+
+// hoistDeclarations 在执行语句列表前先声明其中所有的具名函数, 使它们可以在声明之前使用
+func (s *functionStack) hoistDeclarations(statements []ast.Statement) {
+	for _, statement := range statements {
+		if declaration, ok := statement.(*ast.FunctionDeclaration); ok {
+			s.env.Define(declaration.Name, &Closure{Function: declaration.Function, Env: s.env})
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package interpreter
+
+import (
+	"strings"
+
+	"github.com/bootun/mini-tun/pkg/ast"
+)
+
+// StructType 是结构体声明在运行时的表示, 记录了字段的顺序以及字段名到下标的映射
+type StructType struct {
+	Name   string
+	Fields []string
+	index  map[string]int
+}
+
+func NewStructType(declaration *ast.StructDecl) *StructType {
+	t := &StructType{
+		Name:   declaration.Name,
+		Fields: make([]string, 0, len(declaration.Fields)),
+		index:  make(map[string]int, len(declaration.Fields)),
+	}
+	for i, field := range declaration.Fields {
+		t.Fields = append(t.Fields, field.Value)
+		t.index[field.Value] = i
+	}
+	return t
+}
+
+// FieldIndex 返回字段在结构体中的下标, 字段不存在时ok为false
+func (t *StructType) FieldIndex(name string) (int, bool) {
+	i, ok := t.index[name]
+	return i, ok
+}
+
+func (t *StructType) String() string {
+	return "struct " + t.Name
+}
+
+// StructValue 是结构体实例, 字段的值按声明顺序保存在切片中, 和数组一样, 结构体也是引用类型
+type StructValue struct {
+	Type   *StructType
+	Fields []interface{}
+}
+
+func (v *StructValue) String() string {
+	fields := make([]string, 0, len(v.Fields))
+	for i, name := range v.Type.Fields {
+		fields = append(fields, name+": "+inspectValue(v.Fields[i]))
+	}
+	return v.Type.Name + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// declareStructs 声明语句列表中的结构体. 结构体不是值, 同名的变量和参数不会遮蔽结构体
+func (s *functionStack) declareStructs(statements []ast.Statement) {
+	for _, statement := range statements {
+		if declaration, ok := statement.(*ast.StructDecl); ok {
+			s.structs[declaration.Name] = NewStructType(declaration)
+		}
+	}
+}
+
+// computeStructLiteral 创建结构体实例, 每个字段都必须被赋值
+func (s *functionStack) computeStructLiteral(node *ast.StructLiteral) (interface{}, error) {
+	structType, ok := s.structs[node.Name]
+	if !ok {
+		return nil, runtimeError(node, "undefined struct: %s", node.Name)
+	}
+	fields := make([]interface{}, len(structType.Fields))
+	assigned := make([]bool, len(structType.Fields))
+	for _, field := range node.Fields {
+		i, ok := structType.FieldIndex(field.Name)
+		if !ok {
+			return nil, runtimeError(field.Value, "unknown field %s in struct %s", field.Name, structType.Name)
+		}
+		if assigned[i] {
+			return nil, runtimeError(field.Value, "duplicate field %s in struct literal", field.Name)
+		}
+		fieldValue, err := s.computeExpression(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = fieldValue
+		assigned[i] = true
+	}
+	var missing []string
+	for i, ok := range assigned {
+		if !ok {
+			missing = append(missing, structType.Fields[i])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, runtimeError(node, "missing fields %s in struct literal of %s", strings.Join(missing, ", "), structType.Name)
+	}
+	return &StructValue{Type: structType, Fields: fields}, nil
+}
+
+// computeField 计算字段访问中的结构体实例以及字段的下标
+func (s *functionStack) computeField(node *ast.FieldAccess) (*StructValue, int, error) {
+	left, err := s.computeExpression(node.Left)
+	if err != nil {
+		return nil, 0, err
+	}
+	value, ok := left.(*StructValue)
+	if !ok {
+		return nil, 0, runtimeError(node, "cannot access field %s of %s", node.Field, typeName(left))
+	}
+	i, ok := value.Type.FieldIndex(node.Field)
+	if !ok {
+		return nil, 0, runtimeError(node, "unknown field %s in struct %s", node.Field, value.Type.Name)
+	}
+	return value, i, nil
+}
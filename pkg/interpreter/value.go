@@ -0,0 +1,120 @@
+package interpreter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bootun/mini-tun/pkg/ast"
+	"github.com/bootun/mini-tun/pkg/token"
+)
+
+// Closure 是函数在运行时的值, 由函数字面量和定义它时所在的作用域组成,
+// 函数体中的自由变量会在该作用域中查找, 因此函数可以读写外层函数的变量
+type Closure struct {
+	Function *ast.FunctionLiteral
+	Env      *Environment
+}
+
+func (c *Closure) String() string {
+	return c.Function.TokenLiteral()
+}
+
+// Array 是数组在运行时的值, 数组是引用类型, 赋值和传参时共享同一份元素
+type Array struct {
+	Elements []interface{}
+}
+
+func (a *Array) String() string {
+	elements := make([]string, 0, len(a.Elements))
+	for _, element := range a.Elements {
+		elements = append(elements, inspectValue(element))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// Map 是字典在运行时的值, 键只能是int或string, 遍历时按照键第一次插入的顺序进行,
+// 和数组一样, 字典也是引用类型
+type Map struct {
+	keys   []interface{}
+	values map[interface{}]interface{}
+}
+
+func NewMap() *Map {
+	return &Map{values: make(map[interface{}]interface{})}
+}
+
+// Get 返回键对应的值, 键不存在时ok为false
+func (m *Map) Get(key interface{}) (value interface{}, ok bool) {
+	value, ok = m.values[key]
+	return value, ok
+}
+
+// Set 设置键对应的值, 新的键会被追加到遍历顺序的末尾
+func (m *Map) Set(key, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Keys 按插入顺序返回所有的键
+func (m *Map) Keys() []interface{} {
+	keys := make([]interface{}, len(m.keys))
+	copy(keys, m.keys)
+	return keys
+}
+
+func (m *Map) Len() int {
+	return len(m.keys)
+}
+
+func (m *Map) String() string {
+	pairs := make([]string, 0, len(m.keys))
+	for _, key := range m.keys {
+		pairs = append(pairs, inspectValue(key)+": "+inspectValue(m.values[key]))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// isPrimitive 判断值是否为基本类型 int、float、bool、string
+func isPrimitive(value interface{}) bool {
+	switch value.(type) {
+	case int, float64, bool, string:
+		return true
+	}
+	return false
+}
+
+// formatValue 返回值用于字符串拼接和插值时的文本形式
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return token.FormatFloat(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	case *Closure:
+		return v.String()
+	case *Builtin:
+		return v.String()
+	case *Array:
+		return v.String()
+	case *Map:
+		return v.String()
+	case *StructValue:
+		return v.String()
+	default:
+		return typeName(value)
+	}
+}
+
+// inspectValue 返回值在数组和字典中显示的文本形式, 字符串会带上引号
+func inspectValue(value interface{}) string {
+	if v, ok := value.(string); ok {
+		return strconv.Quote(v)
+	}
+	return formatValue(value)
+}
@@ -2,91 +2,352 @@ package lexer
 
 import (
 	"strings"
+	"unicode/utf8"
 
+	"github.com/bootun/mini-tun/pkg/diagnostics"
 	"github.com/bootun/mini-tun/pkg/token"
 )
 
 type Lexer struct {
-	input string
-	pos   int
+	input        string
+	filename     string
+	keepComments bool             // 是否输出注释token
+	pos          int              // 下一个待读取字符的偏移量
+	line         int              // 当前行号
+	lineStart    int              // 当前行首字符的偏移量
+	errors       diagnostics.List // 词法分析过程中遇到的错误
+
+	// 字符串插值状态, 每个元素对应一层尚未结束的 ${ ... }
+	interpolations []interpolation
+	// 下一个token是 ${
+	pendingInterpolation bool
+	// 插值刚刚结束, 下一个token是字符串的剩余部分
+	resumeString bool
+}
+
+type Option func(*Lexer)
+
+// WithFilename 设置源码的文件名, 文件名会记录在每个token的位置信息中
+func WithFilename(filename string) Option {
+	return func(l *Lexer) {
+		l.filename = filename
+	}
 }
 
-func New(input string) *Lexer {
-	return &Lexer{
+// WithComments 让词法分析器把注释作为 token.COMMENT 输出, 默认情况下注释会被跳过
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.keepComments = true
+	}
+}
+
+func New(input string, opts ...Option) *Lexer {
+	l := &Lexer{
 		input: input,
 		pos:   0,
+		line:  1,
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
 }
 
 func (l *Lexer) Parse() ([]token.Token, error) {
 	tokens := make([]token.Token, 0, 10)
-	for tok := l.nextToken(); tok.GetType() != token.EOF; tok = l.nextToken() {
+	tok := l.nextToken()
+	for ; tok.GetType() != token.EOF; tok = l.nextToken() {
+		if tok.GetType() == token.COMMENT && !l.keepComments {
+			continue
+		}
 		tokens = append(tokens, tok)
 	}
-	tokens = append(tokens, token.New(token.EOF, ""))
-	return tokens, nil
+	tokens = append(tokens, tok)
+	return tokens, l.errors.Err()
 }
 
 func (l *Lexer) nextToken() token.Token {
-	if l.pos >= len(l.input) {
-		return token.New(token.EOF, "")
+	if l.pendingInterpolation {
+		return l.readInterpolationStart()
+	}
+	if l.resumeString {
+		l.resumeString = false
+		return l.readString(l.position())
 	}
-
 	// 吞掉空格
-	for isSpace(l.input[l.pos]) {
-		l.pos++
-		if l.pos >= len(l.input) {
-			return token.New(token.EOF, "")
+	for l.pos < len(l.input) && isSpace(l.input[l.pos]) {
+		l.readChar()
+	}
+	start := l.position()
+	if l.pos >= len(l.input) {
+		for _, interp := range l.interpolations {
+			l.errorf(interp.start, "unterminated string interpolation")
 		}
+		l.interpolations = nil
+		return l.newToken(token.EOF, "", start)
 	}
+
 	ch := l.readChar()
 	switch ch {
 	case '+':
-		return token.New(token.PLUS, "+")
+		if l.match('=') {
+			return l.newToken(token.PLUS_ASSIGN, "+=", start)
+		}
+		return l.newToken(token.PLUS, "+", start)
 	case '-':
-		return token.New(token.MINUS, "-")
+		if l.match('=') {
+			return l.newToken(token.MINUS_ASSIGN, "-=", start)
+		}
+		return l.newToken(token.MINUS, "-", start)
+	case '*':
+		if l.match('=') {
+			return l.newToken(token.ASTERISK_ASSIGN, "*=", start)
+		}
+		return l.newToken(token.ASTERISK, "*", start)
+	case '/':
+		if l.match('/') {
+			return l.readLineComment(start)
+		}
+		if l.match('*') {
+			return l.readBlockComment(start)
+		}
+		if l.match('=') {
+			return l.newToken(token.SLASH_ASSIGN, "/=", start)
+		}
+		return l.newToken(token.SLASH, "/", start)
+	case '%':
+		return l.newToken(token.PERCENT, "%", start)
+	case '!':
+		if l.match('=') {
+			return l.newToken(token.NOT_EQ, "!=", start)
+		}
+		return l.newToken(token.BANG, "!", start)
 	case '=':
-		return token.New(token.EQUAL, "=")
+		if l.match('=') {
+			return l.newToken(token.EQ, "==", start)
+		}
+		return l.newToken(token.EQUAL, "=", start)
+	case '<':
+		if l.match('=') {
+			return l.newToken(token.LTE, "<=", start)
+		}
+		return l.newToken(token.LT, "<", start)
+	case '>':
+		if l.match('=') {
+			return l.newToken(token.GTE, ">=", start)
+		}
+		return l.newToken(token.GT, ">", start)
+	case '&':
+		if l.match('&') {
+			return l.newToken(token.AND, "&&", start)
+		}
+		return l.illegal(start)
+	case '|':
+		if l.match('|') {
+			return l.newToken(token.OR, "||", start)
+		}
+		return l.illegal(start)
 	case '(':
-		return token.New(token.LPAREN, "(")
+		return l.newToken(token.LPAREN, "(", start)
 	case ')':
-		return token.New(token.RPAREN, ")")
+		return l.newToken(token.RPAREN, ")", start)
 	case '{':
-		return token.New(token.LBRACE, "{")
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1].depth++
+		}
+		return l.newToken(token.LBRACE, "{", start)
 	case '}':
-		return token.New(token.RBRACE, "}")
+		if n := len(l.interpolations); n > 0 {
+			if l.interpolations[n-1].depth == 0 {
+				l.interpolations = l.interpolations[:n-1]
+				l.resumeString = true
+				return l.newToken(token.INTERPOLATION_END, "}", start)
+			}
+			l.interpolations[n-1].depth--
+		}
+		return l.newToken(token.RBRACE, "}", start)
+	case '"':
+		return l.readString(start)
+	case '[':
+		return l.newToken(token.LBRACKET, "[", start)
+	case ']':
+		return l.newToken(token.RBRACKET, "]", start)
 	case ',':
-		return token.New(token.COMMA, ",")
+		return l.newToken(token.COMMA, ",", start)
+	case ':':
+		return l.newToken(token.COLON, ":", start)
+	case '.':
+		return l.newToken(token.DOT, ".", start)
+	case ';':
+		return l.newToken(token.SEMICOLON, ";", start)
 	default:
-		var buf strings.Builder
-		buf.WriteByte(ch)
-		for i := l.pos; i < len(l.input) && !isSpace(l.input[i]); i++ {
-			if !isLetter(l.input[i]) && !isNumber(l.input[i]) {
-				break
+		if isNumber(ch) {
+			return l.readNumber(start)
+		}
+		if isLetter(ch) {
+			return l.readWord(ch, start)
+		}
+		return l.illegal(start)
+	}
+}
+
+// illegal 将start处无法识别的字符作为 token.ILLEGAL 返回, 并记录错误, 词法分析会继续进行
+func (l *Lexer) illegal(start token.Position) token.Token {
+	// 按UTF-8字符而不是字节报告错误
+	r, size := utf8.DecodeRuneInString(l.input[start.Offset:])
+	for l.pos < start.Offset+size {
+		l.readChar()
+	}
+	l.errorf(start, "illegal character %q", r)
+	return l.newToken(token.ILLEGAL, l.input[start.Offset:l.pos], start)
+}
+
+// readNumber 读取整数或浮点数字面值, 第一个数字已经被读取
+func (l *Lexer) readNumber(start token.Position) token.Token {
+	typ := token.INT
+	first := l.input[start.Offset]
+	if first == '0' && l.pos < len(l.input) && strings.IndexByte("xXoObB", l.input[l.pos]) >= 0 {
+		// 0x、0o、0b 前缀, 数字的合法性交给 token.ParseInt 检查
+		l.readChar()
+		l.readDigits(isAlphanumeric)
+	} else {
+		l.readDigits(isNumber)
+		if l.peekIs('.') && l.pos+1 < len(l.input) && isNumber(l.input[l.pos+1]) {
+			typ = token.FLOAT
+			l.readChar()
+			l.readDigits(isNumber)
+		}
+		if l.peekIs('e') || l.peekIs('E') {
+			next := l.pos + 1
+			if next < len(l.input) && (l.input[next] == '+' || l.input[next] == '-') {
+				next++
 			}
-			buf.WriteByte(l.input[i])
-			l.pos++
+			if next < len(l.input) && isNumber(l.input[next]) {
+				typ = token.FLOAT
+				for l.pos < next {
+					l.readChar()
+				}
+				l.readDigits(isNumber)
+			}
+		}
+	}
+	// 数字后面紧跟的字母也属于这个字面值, 如 12abc 是一个非法的数字
+	l.readDigits(isAlphanumeric)
+	literal := l.input[start.Offset:l.pos]
+
+	var err error
+	if typ == token.FLOAT {
+		_, err = token.ParseFloat(literal)
+	} else {
+		_, err = token.ParseInt(literal)
+	}
+	if err != nil {
+		l.errorf(start, "%v", err)
+		return l.newToken(token.ILLEGAL, literal, start)
+	}
+	return l.newToken(typ, literal, start)
+}
+
+// readDigits 读取满足accept的字符以及 _ 分隔符
+func (l *Lexer) readDigits(accept func(byte) bool) {
+	for l.pos < len(l.input) && (accept(l.input[l.pos]) || l.input[l.pos] == '_') {
+		l.readChar()
+	}
+}
+
+func (l *Lexer) peekIs(ch byte) bool {
+	return l.pos < len(l.input) && l.input[l.pos] == ch
+}
+
+// readWord 读取以ch开头的标识符或关键字
+func (l *Lexer) readWord(ch byte, start token.Position) token.Token {
+	var buf strings.Builder
+	buf.WriteByte(ch)
+	for l.pos < len(l.input) && !isSpace(l.input[l.pos]) {
+		if !isLetter(l.input[l.pos]) && !isNumber(l.input[l.pos]) {
+			break
+		}
+		buf.WriteByte(l.readChar())
+	}
+	tk := buf.String()
+	typ := token.LookupIdent(tk)
+	return l.newToken(typ, tk, start)
+}
+
+// readLineComment 读取 // 注释直到行尾, 不包含换行符
+func (l *Lexer) readLineComment(start token.Position) token.Token {
+	for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+		l.readChar()
+	}
+	return l.newToken(token.COMMENT, l.input[start.Offset:l.pos], start)
+}
+
+// readBlockComment 读取 /* */ 注释, 块注释不支持嵌套
+func (l *Lexer) readBlockComment(start token.Position) token.Token {
+	for {
+		if l.pos >= len(l.input) {
+			l.errorf(start, "unterminated block comment")
+			break
 		}
-		if buf.Len() == 0 {
-			return token.New(token.EOF, "")
+		if l.input[l.pos] == '*' && l.pos+1 < len(l.input) && l.input[l.pos+1] == '/' {
+			l.readChar()
+			l.readChar()
+			break
 		}
-		tk := buf.String()
-		typ := token.LookupIdent(tk)
-		return token.New(typ, tk)
+		if l.input[l.pos] == '/' && l.pos+1 < len(l.input) && l.input[l.pos+1] == '*' {
+			l.errorf(l.position(), "nested block comment is not supported")
+			l.readChar()
+		}
+		l.readChar()
 	}
+	return l.newToken(token.COMMENT, l.input[start.Offset:l.pos], start)
+}
 
+// errorf 记录一个词法错误, 错误的区间从pos开始, 到当前读取的位置结束
+func (l *Lexer) errorf(pos token.Position, format string, args ...interface{}) {
+	span := token.Span{Start: pos, End: l.position()}
+	l.errors.Add(diagnostics.Errorf(span, diagnostics.InvalidToken, format, args...))
 }
+
 func (l *Lexer) readChar() byte {
 	if l.pos >= len(l.input) {
 		return 0
 	}
 	pos := l.pos
 	l.pos++
+	if l.input[pos] == '\n' {
+		l.line++
+		l.lineStart = l.pos
+	}
 	return l.input[pos]
 }
 
+// match 如果下一个字符是expected, 则读取它并返回true
+func (l *Lexer) match(expected byte) bool {
+	if l.pos >= len(l.input) || l.input[l.pos] != expected {
+		return false
+	}
+	l.readChar()
+	return true
+}
+
+// position 返回下一个待读取字符的位置
+func (l *Lexer) position() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.pos,
+		Line:     l.line,
+		Column:   l.pos - l.lineStart + 1,
+	}
+}
+
+// newToken 创建一个从start开始, 到当前读取位置结束的token
+func (l *Lexer) newToken(typ token.TokenType, literal string, start token.Position) token.Token {
+	return token.NewWithSpan(typ, literal, token.Span{Start: start, End: l.position()})
+}
+
 func isSpace(b byte) bool {
-	return b == ' ' || b == '\n' || b == '\t'
+	return b == ' ' || b == '\n' || b == '\t' || b == '\r'
 }
 
 func isLetter(b byte) bool {
@@ -96,3 +357,7 @@ func isLetter(b byte) bool {
 func isNumber(b byte) bool {
 	return b >= '0' && b <= '9'
 }
+
+func isAlphanumeric(b byte) bool {
+	return isLetter(b) || isNumber(b)
+}
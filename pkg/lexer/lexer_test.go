@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/bootun/mini-tun/pkg/token"
@@ -115,6 +116,160 @@ foo(a, foo(a,b))`,
 			},
 			wantErr: false,
 		},
+		{
+			name: "arithmetic_operators",
+			fields: fields{
+				input: "(a+b)*c/d%e",
+			},
+			want: []token.Token{
+				token.New(token.LPAREN, "("),
+				token.New(token.IDENTIFIER, "a"),
+				token.New(token.PLUS, "+"),
+				token.New(token.IDENTIFIER, "b"),
+				token.New(token.RPAREN, ")"),
+				token.New(token.ASTERISK, "*"),
+				token.New(token.IDENTIFIER, "c"),
+				token.New(token.SLASH, "/"),
+				token.New(token.IDENTIFIER, "d"),
+				token.New(token.PERCENT, "%"),
+				token.New(token.IDENTIFIER, "e"),
+				token.New(token.EOF, ""),
+			},
+			wantErr: false,
+		},
+		{
+			name: "comparison_and_logical_operators",
+			fields: fields{
+				input: "a==b!=c<d<=e>f>=g&&!true||false",
+			},
+			want: []token.Token{
+				token.New(token.IDENTIFIER, "a"),
+				token.New(token.EQ, "=="),
+				token.New(token.IDENTIFIER, "b"),
+				token.New(token.NOT_EQ, "!="),
+				token.New(token.IDENTIFIER, "c"),
+				token.New(token.LT, "<"),
+				token.New(token.IDENTIFIER, "d"),
+				token.New(token.LTE, "<="),
+				token.New(token.IDENTIFIER, "e"),
+				token.New(token.GT, ">"),
+				token.New(token.IDENTIFIER, "f"),
+				token.New(token.GTE, ">="),
+				token.New(token.IDENTIFIER, "g"),
+				token.New(token.AND, "&&"),
+				token.New(token.BANG, "!"),
+				token.New(token.TRUE, "true"),
+				token.New(token.OR, "||"),
+				token.New(token.FALSE, "false"),
+				token.New(token.EOF, ""),
+			},
+			wantErr: false,
+		},
+		{
+			name: "loop_keywords",
+			fields: fields{
+				input: "for ;; { while x { break continue } }",
+			},
+			want: []token.Token{
+				token.New(token.FOR, "for"),
+				token.New(token.SEMICOLON, ";"),
+				token.New(token.SEMICOLON, ";"),
+				token.New(token.LBRACE, "{"),
+				token.New(token.WHILE, "while"),
+				token.New(token.IDENTIFIER, "x"),
+				token.New(token.LBRACE, "{"),
+				token.New(token.BREAK, "break"),
+				token.New(token.CONTINUE, "continue"),
+				token.New(token.RBRACE, "}"),
+				token.New(token.RBRACE, "}"),
+				token.New(token.EOF, ""),
+			},
+			wantErr: false,
+		},
+		{
+			name: "assignment_operators",
+			fields: fields{
+				input: "x+=1 x-=2 x*=3 x/=4",
+			},
+			want: []token.Token{
+				token.New(token.IDENTIFIER, "x"),
+				token.New(token.PLUS_ASSIGN, "+="),
+				token.New(token.INT, "1"),
+				token.New(token.IDENTIFIER, "x"),
+				token.New(token.MINUS_ASSIGN, "-="),
+				token.New(token.INT, "2"),
+				token.New(token.IDENTIFIER, "x"),
+				token.New(token.ASTERISK_ASSIGN, "*="),
+				token.New(token.INT, "3"),
+				token.New(token.IDENTIFIER, "x"),
+				token.New(token.SLASH_ASSIGN, "/="),
+				token.New(token.INT, "4"),
+				token.New(token.EOF, ""),
+			},
+			wantErr: false,
+		},
+		{
+			name: "brackets_and_colon",
+			fields: fields{
+				input: "a[1:] = [2, 3]",
+			},
+			want: []token.Token{
+				token.New(token.IDENTIFIER, "a"),
+				token.New(token.LBRACKET, "["),
+				token.New(token.INT, "1"),
+				token.New(token.COLON, ":"),
+				token.New(token.RBRACKET, "]"),
+				token.New(token.EQUAL, "="),
+				token.New(token.LBRACKET, "["),
+				token.New(token.INT, "2"),
+				token.New(token.COMMA, ","),
+				token.New(token.INT, "3"),
+				token.New(token.RBRACKET, "]"),
+				token.New(token.EOF, ""),
+			},
+			wantErr: false,
+		},
+		{
+			name: "struct_and_field_access",
+			fields: fields{
+				input: "struct P { x } p.x",
+			},
+			want: []token.Token{
+				token.New(token.STRUCT, "struct"),
+				token.New(token.IDENTIFIER, "P"),
+				token.New(token.LBRACE, "{"),
+				token.New(token.IDENTIFIER, "x"),
+				token.New(token.RBRACE, "}"),
+				token.New(token.IDENTIFIER, "p"),
+				token.New(token.DOT, "."),
+				token.New(token.IDENTIFIER, "x"),
+				token.New(token.EOF, ""),
+			},
+			wantErr: false,
+		},
+		{
+			name: "comments_are_skipped",
+			fields: fields{
+				input: `// add two numbers
+let x = a / b // divide
+/* block
+   comment */ let y = 1`,
+			},
+			want: []token.Token{
+				token.New(token.LET, "let"),
+				token.New(token.IDENTIFIER, "x"),
+				token.New(token.EQUAL, "="),
+				token.New(token.IDENTIFIER, "a"),
+				token.New(token.SLASH, "/"),
+				token.New(token.IDENTIFIER, "b"),
+				token.New(token.LET, "let"),
+				token.New(token.IDENTIFIER, "y"),
+				token.New(token.EQUAL, "="),
+				token.New(token.INT, "1"),
+				token.New(token.EOF, ""),
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -124,9 +279,273 @@ foo(a, foo(a,b))`,
 				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
+			if !reflect.DeepEqual(stripSpans(got), tt.want) {
 				t.Errorf("Parse(%v) got = %v, want %v", tt.name, got, tt.want)
 			}
 		})
 	}
 }
+
+// stripSpans 去掉token的位置信息, 只比较类型和字面值
+func stripSpans(tokens []token.Token) []token.Token {
+	stripped := make([]token.Token, 0, len(tokens))
+	for _, tok := range tokens {
+		stripped = append(stripped, token.New(tok.Type, tok.Literal))
+	}
+	return stripped
+}
+
+func TestLexerPosition(t *testing.T) {
+	input := "let x = 10\n\tfoo(x)"
+	tokens, err := New(input, WithFilename("main.tun")).Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	pos := func(offset, line, column int) token.Position {
+		return token.Position{Filename: "main.tun", Offset: offset, Line: line, Column: column}
+	}
+	want := []token.Span{
+		{Start: pos(0, 1, 1), End: pos(3, 1, 4)},   // let
+		{Start: pos(4, 1, 5), End: pos(5, 1, 6)},   // x
+		{Start: pos(6, 1, 7), End: pos(7, 1, 8)},   // =
+		{Start: pos(8, 1, 9), End: pos(10, 1, 11)}, // 10
+		{Start: pos(12, 2, 2), End: pos(15, 2, 5)}, // foo
+		{Start: pos(15, 2, 5), End: pos(16, 2, 6)}, // (
+		{Start: pos(16, 2, 6), End: pos(17, 2, 7)}, // x
+		{Start: pos(17, 2, 7), End: pos(18, 2, 8)}, // )
+		{Start: pos(18, 2, 8), End: pos(18, 2, 8)}, // EOF
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("Parse() got %d tokens, want %d", len(tokens), len(want))
+	}
+	for i, tok := range tokens {
+		if tok.GetSpan() != want[i] {
+			t.Errorf("token %d (%s) span = %+v, want %+v", i, tok.GetLiteral(), tok.GetSpan(), want[i])
+		}
+	}
+	if got := tokens[4].GetSpan().Start.String(); got != "main.tun:2:2" {
+		t.Errorf("Position.String() = %s, want main.tun:2:2", got)
+	}
+}
+
+func TestLexerComments(t *testing.T) {
+	input := "let x = 1 // one\n/* two */ x"
+	got, err := New(input, WithComments()).Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []token.Token{
+		token.New(token.LET, "let"),
+		token.New(token.IDENTIFIER, "x"),
+		token.New(token.EQUAL, "="),
+		token.New(token.INT, "1"),
+		token.New(token.COMMENT, "// one"),
+		token.New(token.COMMENT, "/* two */"),
+		token.New(token.IDENTIFIER, "x"),
+		token.New(token.EOF, ""),
+	}
+	if !reflect.DeepEqual(stripSpans(got), want) {
+		t.Errorf("Parse() got = %v, want %v", got, want)
+	}
+}
+
+func TestLexerCommentErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "unterminated_block_comment",
+			input:   "let x = 1\n/* never closed",
+			wantErr: "c.tun:2:1: unterminated block comment",
+		},
+		{
+			name:    "nested_block_comment",
+			input:   "/* outer /* inner */ */",
+			wantErr: "c.tun:1:10: nested block comment is not supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.input, WithFilename("c.tun")).Parse()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLexerIllegalCharacters(t *testing.T) {
+	input := "let a = 1 @ 2\nlet b = a & c # 中"
+	got, err := New(input, WithFilename("bad.tun")).Parse()
+	if err == nil {
+		t.Fatalf("Parse() error = nil, want illegal character errors")
+	}
+	wantErrs := []string{
+		`bad.tun:1:11: illegal character '@'`,
+		`bad.tun:2:11: illegal character '&'`,
+		`bad.tun:2:15: illegal character '#'`,
+		`bad.tun:2:17: illegal character '中'`,
+	}
+	for _, want := range wantErrs {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse() error = %v, want %q", err, want)
+		}
+	}
+	// 遇到非法字符后仍然继续扫描
+	want := []token.Token{
+		token.New(token.LET, "let"),
+		token.New(token.IDENTIFIER, "a"),
+		token.New(token.EQUAL, "="),
+		token.New(token.INT, "1"),
+		token.New(token.ILLEGAL, "@"),
+		token.New(token.INT, "2"),
+		token.New(token.LET, "let"),
+		token.New(token.IDENTIFIER, "b"),
+		token.New(token.EQUAL, "="),
+		token.New(token.IDENTIFIER, "a"),
+		token.New(token.ILLEGAL, "&"),
+		token.New(token.IDENTIFIER, "c"),
+		token.New(token.ILLEGAL, "#"),
+		token.New(token.ILLEGAL, "中"),
+		token.New(token.EOF, ""),
+	}
+	if !reflect.DeepEqual(stripSpans(got), want) {
+		t.Errorf("Parse() got = %v, want %v", got, want)
+	}
+}
+
+func TestLexerString(t *testing.T) {
+	input := `let s = "tab\t\"q\" \u{4e2d}\\ \${x}" + "n=${n + f("}")}!"`
+	got, err := New(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []token.Token{
+		token.New(token.LET, "let"),
+		token.New(token.IDENTIFIER, "s"),
+		token.New(token.EQUAL, "="),
+		token.New(token.STRING, "tab\t\"q\" 中\\ ${x}"),
+		token.New(token.PLUS, "+"),
+		token.New(token.STRING, "n="),
+		token.New(token.INTERPOLATION_START, "${"),
+		token.New(token.IDENTIFIER, "n"),
+		token.New(token.PLUS, "+"),
+		token.New(token.IDENTIFIER, "f"),
+		token.New(token.LPAREN, "("),
+		token.New(token.STRING, "}"),
+		token.New(token.RPAREN, ")"),
+		token.New(token.INTERPOLATION_END, "}"),
+		token.New(token.STRING, "!"),
+		token.New(token.EOF, ""),
+	}
+	if !reflect.DeepEqual(stripSpans(got), want) {
+		t.Errorf("Parse() got = %v, want %v", got, want)
+	}
+}
+
+func TestLexerStringErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "unterminated_string",
+			input:   "let s = \"abc\nlet t = 1",
+			wantErr: "s.tun:1:9: unterminated string literal",
+		},
+		{
+			name:    "unknown_escape",
+			input:   `let s = "a\qb"`,
+			wantErr: `s.tun:1:11: unknown escape sequence \q`,
+		},
+		{
+			name:    "invalid_unicode_escape",
+			input:   `let s = "\u{110000}"`,
+			wantErr: `s.tun:1:10: invalid unicode code point \u{110000}`,
+		},
+		{
+			name:    "unterminated_interpolation",
+			input:   `let s = "a${b`,
+			wantErr: "s.tun:1:11: unterminated string interpolation",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.input, WithFilename("s.tun")).Parse()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLexerNumber(t *testing.T) {
+	input := "1 1.5 2e10 2.5E-3 0xff 0o17 0b1010 1_000_000 0x_ff 1.e"
+	got, err := New(input).Parse()
+	if err == nil {
+		t.Fatalf("Parse() error = nil, want error for 0x_ff")
+	}
+	want := []token.Token{
+		token.New(token.INT, "1"),
+		token.New(token.FLOAT, "1.5"),
+		token.New(token.FLOAT, "2e10"),
+		token.New(token.FLOAT, "2.5E-3"),
+		token.New(token.INT, "0xff"),
+		token.New(token.INT, "0o17"),
+		token.New(token.INT, "0b1010"),
+		token.New(token.INT, "1_000_000"),
+		token.New(token.ILLEGAL, "0x_ff"),
+		token.New(token.INT, "1"),
+		token.New(token.DOT, "."),
+		token.New(token.IDENTIFIER, "e"),
+		token.New(token.EOF, ""),
+	}
+	if !reflect.DeepEqual(stripSpans(got), want) {
+		t.Errorf("Parse() got = %v, want %v", got, want)
+	}
+}
+
+func TestLexerNumberErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "int_out_of_range",
+			input:   "let a = 9223372036854775808",
+			wantErr: "n.tun:1:9: integer literal out of range: 9223372036854775808",
+		},
+		{
+			name:    "float_out_of_range",
+			input:   "let a = 1e400",
+			wantErr: "n.tun:1:9: float literal out of range: 1e400",
+		},
+		{
+			name:    "invalid_binary_digit",
+			input:   "let a = 0b102",
+			wantErr: "n.tun:1:9: invalid integer literal: 0b102",
+		},
+		{
+			name:    "trailing_separator",
+			input:   "let a = 1_",
+			wantErr: "n.tun:1:9: invalid integer literal: 1_",
+		},
+		{
+			name:    "letters_after_digits",
+			input:   "let a = 12abc",
+			wantErr: "n.tun:1:9: invalid integer literal: 12abc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.input, WithFilename("n.tun")).Parse()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
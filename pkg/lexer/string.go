@@ -0,0 +1,109 @@
+package lexer
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bootun/mini-tun/pkg/token"
+)
+
+// interpolation 记录一层字符串插值 ${ ... }
+type interpolation struct {
+	start token.Position // ${ 的位置
+	depth int            // 插值表达式内尚未闭合的花括号数量
+}
+
+// readString 读取字符串字面值直到结尾的引号或下一个 ${,
+// 开头的引号(或上一个插值的 })已经被读取, 返回的token字面值为转义后的内容
+func (l *Lexer) readString(start token.Position) token.Token {
+	var buf strings.Builder
+	for {
+		if l.pos >= len(l.input) || l.input[l.pos] == '\n' {
+			l.errorf(start, "unterminated string literal")
+			break
+		}
+		ch := l.input[l.pos]
+		if ch == '"' {
+			l.readChar()
+			break
+		}
+		if ch == '$' && l.pos+1 < len(l.input) && l.input[l.pos+1] == '{' {
+			l.pendingInterpolation = true
+			break
+		}
+		if ch == '\\' {
+			l.readEscape(&buf)
+			continue
+		}
+		buf.WriteByte(l.readChar())
+	}
+	return l.newToken(token.STRING, buf.String(), start)
+}
+
+func (l *Lexer) readInterpolationStart() token.Token {
+	l.pendingInterpolation = false
+	start := l.position()
+	l.readChar()
+	l.readChar()
+	l.interpolations = append(l.interpolations, interpolation{start: start})
+	return l.newToken(token.INTERPOLATION_START, "${", start)
+}
+
+// readEscape 读取一个以反斜杠开头的转义序列, 并把转义后的字符写入buf
+func (l *Lexer) readEscape(buf *strings.Builder) {
+	start := l.position()
+	l.readChar()
+	if l.pos >= len(l.input) || l.input[l.pos] == '\n' {
+		l.errorf(start, "unterminated escape sequence")
+		return
+	}
+	ch := l.readChar()
+	switch ch {
+	case 'n':
+		buf.WriteByte('\n')
+	case 't':
+		buf.WriteByte('\t')
+	case 'r':
+		buf.WriteByte('\r')
+	case '"', '\\', '$':
+		buf.WriteByte(ch)
+	case 'u':
+		l.readUnicodeEscape(buf, start)
+	default:
+		r, size := utf8.DecodeRuneInString(l.input[l.pos-1:])
+		for i := 1; i < size; i++ {
+			l.readChar()
+		}
+		l.errorf(start, "unknown escape sequence \\%c", r)
+	}
+}
+
+// readUnicodeEscape 读取 \u{...} 中的十六进制码点
+func (l *Lexer) readUnicodeEscape(buf *strings.Builder, start token.Position) {
+	if l.pos >= len(l.input) || l.input[l.pos] != '{' {
+		l.errorf(start, "invalid unicode escape, expected \\u{...}")
+		return
+	}
+	l.readChar()
+	digitsStart := l.pos
+	for l.pos < len(l.input) && isHexDigit(l.input[l.pos]) {
+		l.readChar()
+	}
+	digits := l.input[digitsStart:l.pos]
+	if l.pos >= len(l.input) || l.input[l.pos] != '}' {
+		l.errorf(start, "invalid unicode escape, expected \\u{...}")
+		return
+	}
+	l.readChar()
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if digits == "" || err != nil || !utf8.ValidRune(rune(code)) {
+		l.errorf(start, "invalid unicode code point \\u{%s}", digits)
+		return
+	}
+	buf.WriteRune(rune(code))
+}
+
+func isHexDigit(b byte) bool {
+	return isNumber(b) || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
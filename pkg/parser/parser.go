@@ -1,10 +1,8 @@
 package parser
 
 import (
-	"fmt"
-	"strconv"
-
 	"github.com/bootun/mini-tun/pkg/ast"
+	"github.com/bootun/mini-tun/pkg/diagnostics"
 	"github.com/bootun/mini-tun/pkg/lexer"
 	"github.com/bootun/mini-tun/pkg/token"
 )
@@ -12,32 +10,90 @@ import (
 type Parser struct {
 	tokens []token.Token
 	curPos int
+	// 正在解析if、while、for的条件, 此时 Name {} 中的花括号是语句块而不是空的结构体字面值
+	noStructLiteral bool
+	// 词法错误和解析过程中遇到的语法错误, 出错的语句会被跳过, 解析从下一条语句继续
+	errors diagnostics.List
 }
 
-func New(l *lexer.Lexer) (*Parser, error) {
+// New 对源码进行词法分析. 词法错误不会中断解析, 它们和语法错误一起由Parse返回
+func New(l *lexer.Lexer) *Parser {
 	p := &Parser{}
 	tokens, err := l.Parse()
-	if err != nil {
-		return nil, fmt.Errorf("lexer parse token error: %v", err)
-	}
+	p.errors = diagnostics.FromError(err)
 
-	p.tokens = tokens
-	return p, nil
+	// 注释不参与语法分析
+	for _, tok := range tokens {
+		if tok.GetType() != token.COMMENT {
+			p.tokens = append(p.tokens, tok)
+		}
+	}
+	return p
 }
 
+// Parse 解析整个程序, 返回的错误包含所有的词法错误和语句中的语法错误, 此时程序中只有没有错误的语句
 func (p *Parser) Parse() (ast.Program, error) {
 	var program ast.Program
 	for p.curPos < len(p.tokens) {
 		if p.tokens[p.curPos].GetType() == token.EOF {
 			break
 		}
-		statement, err := p.parseStatement()
+		start := p.curPos
+		var statement ast.Statement
+		var err error
+		if p.tokens[p.curPos].GetType() == token.STRUCT {
+			statement, err = p.parseStructDeclaration()
+		} else {
+			statement, err = p.parseStatement()
+		}
 		if err != nil {
-			return program, fmt.Errorf("parse statement error: %v", err)
+			p.synchronize(start, err)
+			continue
 		}
 		program.Statements = append(program.Statements, statement)
 	}
-	return program, nil
+	// 词法错误在语法错误之前记录, 排序后错误按在源码中的位置排列
+	p.errors.Sort()
+	return program, p.errors.Err()
+}
+
+// synchronize 记录语句中的语法错误, 然后跳过这条语句, 停在下一条语句的开头.
+// 下一条语句从不在括号中的新的一行开始, 语句块中的语句也会停在语句块的右花括号处.
+// 出错的token位于新的一行时, 通常是上一条语句缺少右括号, 解析从这个token继续
+func (p *Parser) synchronize(start int, err error) {
+	failed := p.curPos
+	// 词法分析已经报告了无法识别的token, 不再重复报告语法错误
+	if failed >= len(p.tokens) || p.tokens[failed].GetType() != token.ILLEGAL {
+		p.errors = append(p.errors, diagnostics.FromError(err)...)
+	}
+	if failed > start && failed < len(p.tokens) &&
+		p.tokens[failed].GetSpan().Start.Line > p.tokens[failed-1].GetSpan().End.Line {
+		return
+	}
+	depth := 0
+	for i := start; i < len(p.tokens); i++ {
+		tok := p.tokens[i]
+		if tok.GetType() == token.EOF {
+			p.curPos = i
+			return
+		}
+		if i > start && i >= failed && depth == 0 {
+			newLine := tok.GetSpan().Start.Line > p.tokens[i-1].GetSpan().End.Line
+			if newLine || tok.GetType() == token.RBRACE {
+				p.curPos = i
+				return
+			}
+		}
+		switch tok.GetType() {
+		case token.LBRACE, token.LPAREN, token.LBRACKET:
+			depth++
+		case token.RBRACE, token.RPAREN, token.RBRACKET:
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+	p.curPos = len(p.tokens) - 1
 }
 
 func (p *Parser) parseStatement() (ast.Statement, error) {
@@ -45,191 +101,1081 @@ func (p *Parser) parseStatement() (ast.Statement, error) {
 	case token.LET:
 		statement, err := p.parseLetStatement()
 		if err != nil {
-			return nil, fmt.Errorf("parse let statement error: %v", err)
+			return nil, err
 		}
 		return statement, nil
 	case token.RETURN:
 		statement, err := p.parseReturnStatement()
 		if err != nil {
-			return nil, fmt.Errorf("parse return statement error: %v", err)
+			return nil, err
+		}
+		return statement, nil
+	case token.IF:
+		statement, err := p.parseIfStatement()
+		if err != nil {
+			return nil, err
+		}
+		return statement, nil
+	case token.WHILE:
+		statement, err := p.parseWhileStatement()
+		if err != nil {
+			return nil, err
+		}
+		return statement, nil
+	case token.FOR:
+		statement, err := p.parseForStatement()
+		if err != nil {
+			return nil, err
 		}
 		return statement, nil
+	case token.BREAK:
+		statement := ast.NewBreakStatement()
+		statement.SetSpan(p.tokens[p.curPos].GetSpan())
+		p.curPos++
+		return statement, nil
+	case token.CONTINUE:
+		statement := ast.NewContinueStatement()
+		statement.SetSpan(p.tokens[p.curPos].GetSpan())
+		p.curPos++
+		return statement, nil
+	case token.LBRACE:
+		// 语句开头的花括号总是语句块, 字典字面值只能出现在表达式中
+		return p.parseBlockStatement()
+	case token.STRUCT:
+		return nil, p.errorf(p.tokens[p.curPos], "struct declaration is only allowed at top level")
+	case token.IDENTIFIER:
+		if isAssignOperator(p.peekToken().GetType()) {
+			statement, err := p.parseAssignment()
+			if err != nil {
+				return nil, err
+			}
+			return statement, nil
+		}
+		return p.parseExpressionStatement()
+	case token.FUNCTION:
+		if p.peekToken().GetType() == token.IDENTIFIER {
+			statement, err := p.parseFunctionDeclaration()
+			if err != nil {
+				return nil, err
+			}
+			return statement, nil
+		}
+		return p.parseExpressionStatement()
 	default:
-		return nil, fmt.Errorf("unexpected token type: %v", p.tokens[p.curPos].GetLiteral())
+		return p.parseExpressionStatement()
+	}
+}
+
+// parseExpressionStatement 解析表达式语句, 如果表达式后面是赋值运算符, 则解析为对下标或字段的赋值 a[i] = v、p.x = v
+func (p *Parser) parseExpressionStatement() (ast.Statement, error) {
+	start := p.tokens[p.curPos]
+	expression, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if operator := p.tokens[p.curPos]; isAssignOperator(operator.GetType()) {
+		p.curPos++
+		value, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		var assignment ast.Statement
+		switch target := expression.(type) {
+		case *ast.IndexExpression:
+			assignment = ast.NewIndexAssignment(target, operator, value)
+		case *ast.FieldAccess:
+			assignment = ast.NewFieldAssignment(target, operator, value)
+		default:
+			return nil, p.errorf(start, "cannot assign to %s", expression.TokenLiteral())
+		}
+		assignment.SetSpan(p.spanFrom(start))
+		return assignment, nil
 	}
+	statement := ast.NewExpressionStatement(expression)
+	statement.SetSpan(p.spanFrom(start))
+	return statement, nil
 }
 
 func (p *Parser) parseLetStatement() (ast.Statement, error) {
-	if p.tokens[p.curPos].GetType() != token.LET {
-		return nil, fmt.Errorf("invalid token type, expected let, but got %v", p.tokens[p.curPos].GetLiteral())
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.LET {
+		return nil, p.unexpected("let")
 	}
 	p.curPos++
 	if p.tokens[p.curPos].GetType() != token.IDENTIFIER {
-		return nil, fmt.Errorf("invalid token type, expected identifier, but got %v", p.tokens[p.curPos].GetLiteral())
+		return nil, p.unexpected("identifier")
 	}
 	letStatement := ast.NewVariableAssignment(p.tokens[p.curPos].GetLiteral(), nil)
 	p.curPos++
+	if p.tokens[p.curPos].GetType() == token.COLON {
+		p.curPos++
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		letStatement.Type = typ
+	}
 	if p.tokens[p.curPos].GetType() != token.EQUAL {
-		return nil, fmt.Errorf("invalid token type, expected equal, but got %v", p.tokens[p.curPos].GetLiteral())
+		return nil, p.unexpected("equal")
 	}
 	p.curPos++
 	expression, err := p.parseExpression()
 	if err != nil {
-		return nil, fmt.Errorf("parse literal error: %v", err)
+		return nil, err
 	}
 	letStatement.Value = expression
+	letStatement.SetSpan(p.spanFrom(start))
 	return letStatement, nil
 }
 
+func (p *Parser) parseAssignment() (ast.Statement, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.IDENTIFIER {
+		return nil, p.unexpected("identifier")
+	}
+	p.curPos++
+	operator := p.tokens[p.curPos]
+	if !isAssignOperator(operator.GetType()) {
+		return nil, p.unexpected("assignment operator")
+	}
+	p.curPos++
+	expression, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	assignment := ast.NewAssignment(start.GetLiteral(), operator, expression)
+	assignment.SetSpan(p.spanFrom(start))
+	return assignment, nil
+}
+
+func isAssignOperator(typ token.TokenType) bool {
+	switch typ {
+	case token.EQUAL, token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN, token.SLASH_ASSIGN:
+		return true
+	}
+	return false
+}
+
 func (p *Parser) parseReturnStatement() (ast.Statement, error) {
-	if p.tokens[p.curPos].GetType() != token.RETURN {
-		return nil, fmt.Errorf("invalid token type, expected return, but got %v", p.tokens[p.curPos].GetLiteral())
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.RETURN {
+		return nil, p.unexpected("return")
 	}
 	p.curPos++
 	expression, err := p.parseExpression()
 	if err != nil {
-		return nil, fmt.Errorf("parse literal error: %v", err)
+		return nil, err
 	}
-	return ast.NewReturnStatement(expression), nil
+	returnStatement := ast.NewReturnStatement(expression)
+	returnStatement.SetSpan(p.spanFrom(start))
+	return returnStatement, nil
+}
+
+// 运算符优先级, 数值越大优先级越高
+const (
+	_ int = iota
+	LOWEST
+	OR          // ||
+	AND         // &&
+	EQUALS      // == !=
+	LESSGREATER // < <= > >=
+	SUM         // + -
+	PRODUCT     // * / %
+	PREFIX      // -x +x !x
+	// a[i] a[lo:hi] p.x f() 等后缀运算的优先级最高, 由 parsePostfixExpression 处理
+)
+
+var precedences = map[token.TokenType]int{
+	token.OR:       OR,
+	token.AND:      AND,
+	token.EQ:       EQUALS,
+	token.NOT_EQ:   EQUALS,
+	token.LT:       LESSGREATER,
+	token.LTE:      LESSGREATER,
+	token.GT:       LESSGREATER,
+	token.GTE:      LESSGREATER,
+	token.PLUS:     SUM,
+	token.MINUS:    SUM,
+	token.ASTERISK: PRODUCT,
+	token.SLASH:    PRODUCT,
+	token.PERCENT:  PRODUCT,
 }
+
 func (p *Parser) parseExpression() (ast.Expression, error) {
-	curToken := p.tokens[p.curPos]
-	nextToken := p.peekToken()
-	switch nextToken.GetType() {
-	case token.PLUS, token.MINUS:
-		return p.parseComplexExpression()
+	return p.parseBinaryExpression(LOWEST)
+}
+
+// parseBinaryExpression 使用优先级爬升法解析二元表达式,
+// 只有优先级高于precedence的运算符才会被当前调用消费, 因此同级运算符是左结合的
+func (p *Parser) parseBinaryExpression(precedence int) (ast.Expression, error) {
+	start := p.tokens[p.curPos]
+	left, err := p.parsePostfixExpression()
+	if err != nil {
+		return nil, err
+	}
+	for precedence < p.curPrecedence() {
+		operator := p.tokens[p.curPos]
+		p.curPos++
+		right, err := p.parseBinaryExpression(precedences[operator.GetType()])
+		if err != nil {
+			return nil, err
+		}
+		var expression ast.Expression
+		switch operator.GetType() {
+		case token.AND, token.OR:
+			expression = ast.NewLogicalExpression(left, operator, right)
+		default:
+			expression = ast.NewComplexExpression(left, operator, right)
+		}
+		expression.SetSpan(p.spanFrom(start))
+		left = expression
+	}
+	return left, nil
+}
+
+// parsePostfixExpression 解析基本表达式以及跟在它后面的下标、切片、字段访问和函数调用, 如 a[1][2:]、p.x、f(1)(2)
+func (p *Parser) parsePostfixExpression() (ast.Expression, error) {
+	start := p.tokens[p.curPos]
+	left, err := p.parsePrimaryExpression()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.tokens[p.curPos].GetType() {
+		case token.LBRACKET:
+			if !p.onSameLine() {
+				return left, nil
+			}
+			left, err = p.parseIndexExpression(start, left)
+		case token.DOT:
+			left, err = p.parseFieldAccess(start, left)
+		case token.LPAREN:
+			if !p.onSameLine() {
+				return left, nil
+			}
+			left, err = p.parseCallExpression(start, left)
+		default:
+			return left, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseFieldAccess 解析 left.field, start 是left的第一个token
+func (p *Parser) parseFieldAccess(start token.Token, left ast.Expression) (ast.Expression, error) {
+	if p.tokens[p.curPos].GetType() != token.DOT {
+		return nil, p.unexpected("dot")
+	}
+	p.curPos++
+	if p.tokens[p.curPos].GetType() != token.IDENTIFIER {
+		return nil, p.unexpected("field name")
+	}
+	fieldAccess := ast.NewFieldAccess(left, p.tokens[p.curPos].GetLiteral())
+	p.curPos++
+	fieldAccess.SetSpan(p.spanFrom(start))
+	return fieldAccess, nil
+}
+
+// parseIndexExpression 解析 left[i] 或 left[lo:hi], start 是left的第一个token
+func (p *Parser) parseIndexExpression(start token.Token, left ast.Expression) (ast.Expression, error) {
+	if p.tokens[p.curPos].GetType() != token.LBRACKET {
+		return nil, p.unexpected("left bracket")
+	}
+	p.curPos++
+	var low ast.Expression
+	if p.tokens[p.curPos].GetType() != token.COLON {
+		index, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.tokens[p.curPos].GetType() == token.RBRACKET {
+			p.curPos++
+			indexExpression := ast.NewIndexExpression(left, index)
+			indexExpression.SetSpan(p.spanFrom(start))
+			return indexExpression, nil
+		}
+		low = index
+	}
+	if p.tokens[p.curPos].GetType() != token.COLON {
+		return nil, p.unexpected("right bracket")
+	}
+	p.curPos++
+	var high ast.Expression
+	if p.tokens[p.curPos].GetType() != token.RBRACKET {
+		var err error
+		high, err = p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.tokens[p.curPos].GetType() != token.RBRACKET {
+		return nil, p.unexpected("right bracket")
+	}
+	p.curPos++
+	sliceExpression := ast.NewSliceExpression(left, low, high)
+	sliceExpression.SetSpan(p.spanFrom(start))
+	return sliceExpression, nil
+}
+
+// parseArrayLiteral 解析 [a, b, c], 允许最后一个元素后面有逗号
+func (p *Parser) parseArrayLiteral() (ast.Expression, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.LBRACKET {
+		return nil, p.unexpected("left bracket")
+	}
+	p.curPos++
+	elements := []ast.Expression{}
+	for p.tokens[p.curPos].GetType() != token.RBRACKET {
+		element, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+		if p.tokens[p.curPos].GetType() != token.COMMA {
+			break
+		}
+		p.curPos++
+	}
+	if p.tokens[p.curPos].GetType() != token.RBRACKET {
+		return nil, p.unexpected("comma or right bracket")
+	}
+	p.curPos++
+	arrayLiteral := ast.NewArrayLiteral(elements)
+	arrayLiteral.SetSpan(p.spanFrom(start))
+	return arrayLiteral, nil
+}
+
+// isStructLiteral 判断当前的标识符是否为结构体字面值的开始, 即 Name{field: ...} 或 Name{}
+func (p *Parser) isStructLiteral() bool {
+	if p.tokenAt(1).GetType() != token.LBRACE {
+		return false
+	}
+	switch p.tokenAt(2).GetType() {
+	case token.IDENTIFIER:
+		// 语句块不会以 field: 开头, 因此这种情况没有歧义
+		return p.tokenAt(3).GetType() == token.COLON
+	case token.RBRACE:
+		return !p.noStructLiteral
+	}
+	return false
+}
+
+// parseStructLiteral 解析 Name{field: value, ...}, 允许最后一个字段后面有逗号
+func (p *Parser) parseStructLiteral() (ast.Expression, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.IDENTIFIER {
+		return nil, p.unexpected("identifier")
+	}
+	p.curPos++
+	if p.tokens[p.curPos].GetType() != token.LBRACE {
+		return nil, p.unexpected("left brace")
+	}
+	p.curPos++
+	fields := []*ast.StructField{}
+	for p.tokens[p.curPos].GetType() != token.RBRACE {
+		name := p.tokens[p.curPos]
+		if name.GetType() != token.IDENTIFIER {
+			return nil, p.unexpected("field name")
+		}
+		p.curPos++
+		if p.tokens[p.curPos].GetType() != token.COLON {
+			return nil, p.unexpected("colon")
+		}
+		p.curPos++
+		value, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, &ast.StructField{Name: name.GetLiteral(), Span: name.GetSpan(), Value: value})
+		if p.tokens[p.curPos].GetType() != token.COMMA {
+			break
+		}
+		p.curPos++
+	}
+	if p.tokens[p.curPos].GetType() != token.RBRACE {
+		return nil, p.unexpected("comma or right brace")
+	}
+	p.curPos++
+	structLiteral := ast.NewStructLiteral(start.GetLiteral(), fields)
+	structLiteral.SetSpan(p.spanFrom(start))
+	return structLiteral, nil
+}
+
+// parseStructDeclaration 解析 struct Name { field: type, ... }, 字段的类型标注可以省略, 允许最后一个字段后面有逗号
+func (p *Parser) parseStructDeclaration() (ast.Statement, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.STRUCT {
+		return nil, p.unexpected("struct")
+	}
+	p.curPos++
+	if p.tokens[p.curPos].GetType() != token.IDENTIFIER {
+		return nil, p.unexpected("identifier")
+	}
+	name := p.tokens[p.curPos].GetLiteral()
+	p.curPos++
+	if p.tokens[p.curPos].GetType() != token.LBRACE {
+		return nil, p.unexpected("left brace")
+	}
+	p.curPos++
+	fields := []*ast.IdentifierExpression{}
+	for p.tokens[p.curPos].GetType() == token.IDENTIFIER {
+		field, err := p.parseTypedIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+		if p.tokens[p.curPos].GetType() != token.COMMA {
+			break
+		}
+		p.curPos++
+	}
+	if p.tokens[p.curPos].GetType() != token.RBRACE {
+		return nil, p.unexpected("field name or right brace")
+	}
+	p.curPos++
+	declaration := ast.NewStructDecl(name, fields)
+	declaration.SetSpan(p.spanFrom(start))
+	return declaration, nil
+}
+
+// parseTypedIdentifier 解析函数参数或结构体字段 name 或 name: type
+func (p *Parser) parseTypedIdentifier() (*ast.IdentifierExpression, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.IDENTIFIER {
+		return nil, p.unexpected("identifier")
+	}
+	identifier := ast.NewIdentifierExpression(start.GetLiteral())
+	identifier.SetSpan(start.GetSpan())
+	p.curPos++
+	if p.tokens[p.curPos].GetType() == token.COLON {
+		p.curPos++
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		identifier.Type = typ
+	}
+	return identifier, nil
+}
+
+// parseType 解析类型标注, 如 int、Point、[]int、map[string]int、function(int, int) int
+func (p *Parser) parseType() (ast.TypeExpression, error) {
+	start := p.tokens[p.curPos]
+	var typ ast.TypeExpression
+	switch start.GetType() {
+	case token.LBRACKET:
+		p.curPos++
+		if p.tokens[p.curPos].GetType() != token.RBRACKET {
+			return nil, p.unexpected("right bracket")
+		}
+		p.curPos++
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		typ = ast.NewArrayType(elem)
+	case token.FUNCTION:
+		p.curPos++
+		if p.tokens[p.curPos].GetType() != token.LPAREN {
+			return nil, p.unexpected("left parenthesis")
+		}
+		p.curPos++
+		params := []ast.TypeExpression{}
+		for p.tokens[p.curPos].GetType() != token.RPAREN {
+			param, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, param)
+			if p.tokens[p.curPos].GetType() != token.COMMA {
+				break
+			}
+			p.curPos++
+		}
+		if p.tokens[p.curPos].GetType() != token.RPAREN {
+			return nil, p.unexpected("comma or right parenthesis")
+		}
+		p.curPos++
+		// 返回值的类型只能和右括号在同一行, 没有返回值的函数省略返回值的类型
+		var result ast.TypeExpression
+		if p.isTypeStart() && p.onSameLine() {
+			var err error
+			result, err = p.parseType()
+			if err != nil {
+				return nil, err
+			}
+		}
+		typ = ast.NewFunctionType(params, result)
+	case token.IDENTIFIER:
+		p.curPos++
+		if start.GetLiteral() != "map" || p.tokens[p.curPos].GetType() != token.LBRACKET {
+			typ = ast.NewNamedType(start.GetLiteral())
+			break
+		}
+		p.curPos++
+		key, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if p.tokens[p.curPos].GetType() != token.RBRACKET {
+			return nil, p.unexpected("right bracket")
+		}
+		p.curPos++
+		value, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		typ = ast.NewMapType(key, value)
+	default:
+		return nil, p.unexpected("type")
+	}
+	typ.SetSpan(p.spanFrom(start))
+	return typ, nil
+}
+
+// isTypeStart 判断当前token是否可以作为类型标注的开始
+func (p *Parser) isTypeStart() bool {
+	switch p.tokens[p.curPos].GetType() {
+	case token.IDENTIFIER, token.LBRACKET, token.FUNCTION:
+		return true
+	}
+	return false
+}
+
+// parseMapLiteral 解析 {k: v, ...}, 允许最后一个键值对后面有逗号
+func (p *Parser) parseMapLiteral() (ast.Expression, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.LBRACE {
+		return nil, p.unexpected("left brace")
+	}
+	p.curPos++
+	pairs := []*ast.MapPair{}
+	for p.tokens[p.curPos].GetType() != token.RBRACE {
+		key, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.tokens[p.curPos].GetType() != token.COLON {
+			return nil, p.unexpected("colon")
+		}
+		p.curPos++
+		value, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, &ast.MapPair{Key: key, Value: value})
+		if p.tokens[p.curPos].GetType() != token.COMMA {
+			break
+		}
+		p.curPos++
+	}
+	if p.tokens[p.curPos].GetType() != token.RBRACE {
+		return nil, p.unexpected("comma or right brace")
 	}
+	p.curPos++
+	mapLiteral := ast.NewMapLiteral(pairs)
+	mapLiteral.SetSpan(p.spanFrom(start))
+	return mapLiteral, nil
+}
+
+func (p *Parser) parsePrimaryExpression() (ast.Expression, error) {
+	curToken := p.tokens[p.curPos]
 	switch curToken.GetType() {
 	case token.FUNCTION:
 		// 解析函数
 		expression, err := p.parseFunctionDeclareExpression()
 		if err != nil {
-			return nil, fmt.Errorf("parse function expression error: %v", err)
+			return nil, err
 		}
 		return expression, nil
 	case token.INT:
 		return p.parseLiteralExpression()
+	case token.FLOAT:
+		return p.parseFloatLiteral()
+	case token.TRUE, token.FALSE:
+		return p.parseBooleanLiteral()
+	case token.STRING:
+		return p.parseStringLiteral()
 	case token.IDENTIFIER:
-		if p.tokens[p.curPos+1].GetType() == token.LPAREN {
-			return p.parseFunctionCallExpression()
+		if p.isStructLiteral() {
+			return p.parseStructLiteral()
 		}
 		return p.parseIdentifierExpression()
+	case token.LPAREN:
+		return p.parseGroupedExpression()
+	case token.LBRACKET:
+		return p.parseArrayLiteral()
+	case token.LBRACE:
+		return p.parseMapLiteral()
+	case token.MINUS, token.PLUS, token.BANG:
+		return p.parsePrefixExpression()
 	default:
-		return nil, fmt.Errorf("unexpected token type: %v", curToken.GetLiteral())
+		return nil, p.errorf(curToken, "unexpected token type: %v", describeToken(curToken))
+	}
+}
+
+// parsePrefixExpression 解析前缀表达式, 前缀运算符的优先级高于所有二元运算符
+func (p *Parser) parsePrefixExpression() (ast.Expression, error) {
+	operator := p.tokens[p.curPos]
+	p.curPos++
+	right, err := p.parseBinaryExpression(PREFIX)
+	if err != nil {
+		return nil, err
 	}
+	prefixExpression := ast.NewPrefixExpression(operator, right)
+	prefixExpression.SetSpan(p.spanFrom(operator))
+	return prefixExpression, nil
 }
 
-func (p *Parser) parseFunctionCallExpression() (ast.Expression, error) {
-	f := ast.NewFunctionCall(p.tokens[p.curPos].GetLiteral(), nil)
+// parseGroupedExpression 解析括号包裹的表达式, 括号本身不会产生节点
+func (p *Parser) parseGroupedExpression() (ast.Expression, error) {
+	if p.tokens[p.curPos].GetType() != token.LPAREN {
+		return nil, p.unexpected("left parenthesis")
+	}
+	p.curPos++
+	restore := p.allowStructLiteral(true)
+	expression, err := p.parseExpression()
+	restore()
+	if err != nil {
+		return nil, err
+	}
+	if p.tokens[p.curPos].GetType() != token.RPAREN {
+		return nil, p.unexpected("right parenthesis")
+	}
 	p.curPos++
+	return expression, nil
+}
+
+func (p *Parser) curPrecedence() int {
+	if p.curPos >= len(p.tokens) {
+		return 0
+	}
+	return precedences[p.tokens[p.curPos].GetType()]
+}
+
+// parseCallExpression 解析 callee(args...), start 是callee的第一个token, 允许最后一个参数后面有逗号
+func (p *Parser) parseCallExpression(start token.Token, callee ast.Expression) (ast.Expression, error) {
 	if p.tokens[p.curPos].GetType() != token.LPAREN {
-		return nil, fmt.Errorf("invalid token type, expected left parenthesis, but got %v", p.tokens[p.curPos].GetLiteral())
+		return nil, p.unexpected("left parenthesis")
 	}
 	p.curPos++
+	defer p.allowStructLiteral(true)()
+	arguments := []ast.Expression{}
 	for p.tokens[p.curPos].GetType() != token.RPAREN {
-		if p.tokens[p.curPos].GetType() == token.COMMA {
-			p.curPos++
-		} else if p.tokens[p.curPos].GetType() == token.RPAREN {
-			break
-		}
-		expression, err := p.parseExpression()
+		argument, err := p.parseExpression()
 		if err != nil {
-			return nil, fmt.Errorf("parse expression error: %v", err)
+			return nil, err
 		}
-		f.Arguments = append(f.Arguments, expression)
+		arguments = append(arguments, argument)
+		if p.tokens[p.curPos].GetType() != token.COMMA {
+			break
+		}
+		p.curPos++
+	}
+	if p.tokens[p.curPos].GetType() != token.RPAREN {
+		return nil, p.unexpected("comma or right parenthesis")
 	}
 	p.curPos++
-
-	return f, nil
+	call := ast.NewFunctionCall(callee, arguments)
+	call.SetSpan(p.spanFrom(start))
+	return call, nil
 }
 
 func (p *Parser) parseFunctionDeclareExpression() (ast.Expression, error) {
-	if p.tokens[p.curPos].GetType() != token.FUNCTION {
-		return nil, fmt.Errorf("invalid token type, expected function, but got %v", p.tokens[p.curPos].GetLiteral())
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.FUNCTION {
+		return nil, p.unexpected("function")
 	}
-	function := ast.NewFunctionLiteral(nil, nil)
 	p.curPos++
+	return p.parseFunctionParametersAndBody(start)
+}
+
+// parseFunctionDeclaration 解析具名函数声明 function name(params) { ... }
+func (p *Parser) parseFunctionDeclaration() (ast.Statement, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.FUNCTION {
+		return nil, p.unexpected("function")
+	}
+	p.curPos++
+	if p.tokens[p.curPos].GetType() != token.IDENTIFIER {
+		return nil, p.unexpected("identifier")
+	}
+	name := p.tokens[p.curPos].GetLiteral()
+	p.curPos++
+	function, err := p.parseFunctionParametersAndBody(start)
+	if err != nil {
+		return nil, err
+	}
+	declaration := ast.NewFunctionDeclaration(name, function)
+	declaration.SetSpan(p.spanFrom(start))
+	return declaration, nil
+}
+
+// parseFunctionParametersAndBody 解析函数的参数列表和函数体, start 为function关键字
+func (p *Parser) parseFunctionParametersAndBody(start token.Token) (*ast.FunctionLiteral, error) {
+	function := ast.NewFunctionLiteral(nil, nil)
+	if p.tokens[p.curPos].GetType() == token.LT {
+		typeParams, err := p.parseTypeParameters()
+		if err != nil {
+			return nil, err
+		}
+		function.TypeParameters = typeParams
+	}
 	if p.tokens[p.curPos].GetType() != token.LPAREN {
-		return nil, fmt.Errorf("invalid token type, expected left parenthesis, but got %v", p.tokens[p.curPos].GetLiteral())
+		return nil, p.unexpected("left parenthesis")
 	}
 	p.curPos++
 	for p.tokens[p.curPos].GetType() != token.RPAREN {
-		if p.tokens[p.curPos].GetType() == token.COMMA {
+		param, err := p.parseTypedIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		function.Parameters = append(function.Parameters, param)
+		if p.tokens[p.curPos].GetType() != token.COMMA {
+			break
+		}
+		p.curPos++
+	}
+	if p.tokens[p.curPos].GetType() != token.RPAREN {
+		return nil, p.unexpected("comma or right parenthesis")
+	}
+	p.curPos++
+	if p.tokens[p.curPos].GetType() == token.COLON {
+		p.curPos++
+		returnType, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		function.ReturnType = returnType
+	}
+	body, err := p.parseBlockStatement()
+	if err != nil {
+		return nil, err
+	}
+	function.Body = body
+	function.SetSpan(p.spanFrom(start))
+	return function, nil
+}
 
-		} else if p.tokens[p.curPos].GetType() == token.IDENTIFIER {
-			function.Parameters = append(function.Parameters, ast.NewIdentifierExpression(p.tokens[p.curPos].GetLiteral()))
-		} else {
-			return nil, fmt.Errorf("unexpected token type: %v, expected identifier", p.tokens[p.curPos].GetLiteral())
+// parseTypeParameters 解析函数的类型参数列表 <T, U>
+func (p *Parser) parseTypeParameters() ([]*ast.IdentifierExpression, error) {
+	p.curPos++
+	var params []*ast.IdentifierExpression
+	for {
+		tok := p.tokens[p.curPos]
+		if tok.GetType() != token.IDENTIFIER {
+			return nil, p.unexpected("type parameter")
+		}
+		param := ast.NewIdentifierExpression(tok.GetLiteral())
+		param.SetSpan(tok.GetSpan())
+		p.curPos++
+		params = append(params, param)
+		if p.tokens[p.curPos].GetType() != token.COMMA {
+			break
 		}
 		p.curPos++
 	}
+	if p.tokens[p.curPos].GetType() != token.GT {
+		return nil, p.unexpected("right angle bracket")
+	}
 	p.curPos++
-	if p.tokens[p.curPos].GetType() != token.LBRACE {
-		return nil, fmt.Errorf("invalid token type, expected left brace, but got %v", p.tokens[p.curPos].GetLiteral())
+	return params, nil
+}
+
+// parseBlockStatement 解析由花括号包裹的语句块
+func (p *Parser) parseBlockStatement() (*ast.BlockStatement, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.LBRACE {
+		return nil, p.unexpected("left brace")
 	}
 	p.curPos++
-	function.Body = ast.NewBlockStatement(nil)
+	defer p.allowStructLiteral(true)()
+	block := ast.NewBlockStatement(nil)
 	for p.tokens[p.curPos].GetType() != token.RBRACE {
+		if p.tokens[p.curPos].GetType() == token.EOF {
+			return nil, p.unexpected("right brace")
+		}
+		statementStart := p.curPos
 		statement, err := p.parseStatement()
 		if err != nil {
-			return nil, fmt.Errorf("parse statement error: %v", err)
+			p.synchronize(statementStart, err)
+			continue
 		}
-		function.Body.Statements = append(function.Body.Statements, statement)
+		block.Statements = append(block.Statements, statement)
 	}
 	p.curPos++
-	return function, nil
+	block.SetSpan(p.spanFrom(start))
+	return block, nil
 }
 
-func (p *Parser) parseIdentifierExpression() (ast.Expression, error) {
-	if p.tokens[p.curPos].GetType() != token.IDENTIFIER {
-		return nil, fmt.Errorf("invalid token type, expected identifier, but got %v", p.tokens[p.curPos].GetLiteral())
+func (p *Parser) parseWhileStatement() (ast.Statement, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.WHILE {
+		return nil, p.unexpected("while")
 	}
-	identifierExpression := ast.NewIdentifierExpression(p.tokens[p.curPos].GetLiteral())
 	p.curPos++
-	return identifierExpression, nil
+	condition, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.parseBlockStatement()
+	if err != nil {
+		return nil, err
+	}
+	whileStatement := ast.NewWhileStatement(condition, body)
+	whileStatement.SetSpan(p.spanFrom(start))
+	return whileStatement, nil
 }
 
-func (p *Parser) parseComplexExpression() (ast.Expression, error) {
-	complexExpression := ast.NewComplexExpression(nil, token.New(token.EOF, ""), nil)
-	switch p.tokens[p.curPos].GetType() {
-	case token.IDENTIFIER:
-		exp, err := p.parseIdentifierExpression()
+// parseForStatement 解析 for init; condition; post { ... }, 三个子句均可以省略
+func (p *Parser) parseForStatement() (ast.Statement, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.FOR {
+		return nil, p.unexpected("for")
+	}
+	p.curPos++
+	forStatement := ast.NewForStatement(nil, nil, nil, nil)
+	if err := p.parseForClauses(forStatement); err != nil {
+		return nil, err
+	}
+	body, err := p.parseBlockStatement()
+	if err != nil {
+		return nil, err
+	}
+	forStatement.Body = body
+	forStatement.SetSpan(p.spanFrom(start))
+	return forStatement, nil
+}
+
+// parseForClauses 解析for语句的三个子句, 子句中的 Name {} 不会被当作结构体字面值
+func (p *Parser) parseForClauses(forStatement *ast.ForStatement) error {
+	defer p.allowStructLiteral(false)()
+	if p.tokens[p.curPos].GetType() != token.SEMICOLON {
+		init, err := p.parseSimpleStatement()
 		if err != nil {
-			return nil, fmt.Errorf("parse identifier expression error: %v", err)
+			return err
 		}
-		complexExpression.Left = exp
-	case token.INT:
-		exp, err := p.parseLiteralExpression()
+		forStatement.Init = init
+	}
+	if p.tokens[p.curPos].GetType() != token.SEMICOLON {
+		return p.unexpected("semicolon")
+	}
+	p.curPos++
+	if p.tokens[p.curPos].GetType() != token.SEMICOLON {
+		condition, err := p.parseExpression()
 		if err != nil {
-			return nil, fmt.Errorf("parse literal expression error: %v", err)
+			return err
 		}
-		complexExpression.Left = exp
+		forStatement.Condition = condition
+	}
+	if p.tokens[p.curPos].GetType() != token.SEMICOLON {
+		return p.unexpected("semicolon")
 	}
-	complexExpression.Operator = p.tokens[p.curPos]
 	p.curPos++
-	expression, err := p.parseExpression()
+	if p.tokens[p.curPos].GetType() != token.LBRACE {
+		post, err := p.parseSimpleStatement()
+		if err != nil {
+			return err
+		}
+		forStatement.Post = post
+	}
+	return nil
+}
+
+// parseSimpleStatement 解析可以出现在for子句中的语句: 变量声明、赋值以及表达式语句
+func (p *Parser) parseSimpleStatement() (ast.Statement, error) {
+	switch p.tokens[p.curPos].GetType() {
+	case token.LET:
+		return p.parseLetStatement()
+	case token.IDENTIFIER:
+		if isAssignOperator(p.peekToken().GetType()) {
+			return p.parseAssignment()
+		}
+		return p.parseExpressionStatement()
+	default:
+		return p.parseExpressionStatement()
+	}
+}
+
+// parseIfStatement 解析 if cond { ... } else if cond { ... } else { ... }
+func (p *Parser) parseIfStatement() (ast.Statement, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.IF {
+		return nil, p.unexpected("if")
+	}
+	p.curPos++
+	condition, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	consequence, err := p.parseBlockStatement()
 	if err != nil {
-		return nil, fmt.Errorf("parse expression statement error: %v", err)
+		return nil, err
+	}
+	ifStatement := ast.NewIfStatement(condition, consequence, nil)
+	if p.tokens[p.curPos].GetType() == token.ELSE {
+		p.curPos++
+		if p.tokens[p.curPos].GetType() == token.IF {
+			alternative, err := p.parseIfStatement()
+			if err != nil {
+				return nil, err
+			}
+			ifStatement.Alternative = alternative
+		} else {
+			alternative, err := p.parseBlockStatement()
+			if err != nil {
+				return nil, err
+			}
+			ifStatement.Alternative = alternative
+		}
+	}
+	ifStatement.SetSpan(p.spanFrom(start))
+	return ifStatement, nil
+}
+
+func (p *Parser) parseIdentifierExpression() (ast.Expression, error) {
+	if p.tokens[p.curPos].GetType() != token.IDENTIFIER {
+		return nil, p.unexpected("identifier")
 	}
-	complexExpression.Right = expression
-	return complexExpression, nil
+	identifierExpression := ast.NewIdentifierExpression(p.tokens[p.curPos].GetLiteral())
+	identifierExpression.SetSpan(p.tokens[p.curPos].GetSpan())
+	p.curPos++
+	return identifierExpression, nil
 }
 
 func (p *Parser) parseLiteralExpression() (ast.Expression, error) {
 	literalExpression := ast.NewLiteralExpression(0)
 	literal := p.tokens[p.curPos].GetLiteral()
-	if v, err := strconv.ParseInt(literal, 10, 64); err != nil {
-		return nil, fmt.Errorf("parse literal expression error, %v", err)
+	if v, err := token.ParseInt(literal); err != nil {
+		return nil, p.errorf(p.tokens[p.curPos], "parse literal expression error, %v", err)
 	} else {
 		literalExpression.Value = int(v)
 	}
+	literalExpression.SetSpan(p.tokens[p.curPos].GetSpan())
 	p.curPos++
 	return literalExpression, nil
 }
 
+func (p *Parser) parseFloatLiteral() (ast.Expression, error) {
+	tok := p.tokens[p.curPos]
+	v, err := token.ParseFloat(tok.GetLiteral())
+	if err != nil {
+		return nil, p.errorf(tok, "parse float literal error, %v", err)
+	}
+	floatLiteral := ast.NewFloatLiteral(v)
+	floatLiteral.SetSpan(tok.GetSpan())
+	p.curPos++
+	return floatLiteral, nil
+}
+
+func (p *Parser) parseBooleanLiteral() (ast.Expression, error) {
+	tok := p.tokens[p.curPos]
+	booleanLiteral := ast.NewBooleanLiteral(tok.GetType() == token.TRUE)
+	booleanLiteral.SetSpan(tok.GetSpan())
+	p.curPos++
+	return booleanLiteral, nil
+}
+
+// parseStringLiteral 解析字符串字面值, 带插值的字符串 "a${x}b" 会被转换为拼接表达式 "a" + x + "b"
+func (p *Parser) parseStringLiteral() (ast.Expression, error) {
+	start := p.tokens[p.curPos]
+	if start.GetType() != token.STRING {
+		return nil, p.unexpected("string")
+	}
+	var expression ast.Expression = ast.NewStringLiteral(start.GetLiteral())
+	expression.SetSpan(start.GetSpan())
+	p.curPos++
+	for p.tokens[p.curPos].GetType() == token.INTERPOLATION_START {
+		plus := token.NewWithSpan(token.PLUS, "+", p.tokens[p.curPos].GetSpan())
+		p.curPos++
+		value, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.tokens[p.curPos].GetType() != token.INTERPOLATION_END {
+			return nil, p.unexpected("end of string interpolation")
+		}
+		p.curPos++
+		expression = ast.NewComplexExpression(expression, plus, value)
+		expression.SetSpan(p.spanFrom(start))
+
+		fragment := p.tokens[p.curPos]
+		if fragment.GetType() != token.STRING {
+			return nil, p.unexpected("string")
+		}
+		p.curPos++
+		if fragment.GetLiteral() != "" {
+			literal := ast.NewStringLiteral(fragment.GetLiteral())
+			literal.SetSpan(fragment.GetSpan())
+			expression = ast.NewComplexExpression(expression, plus, literal)
+			expression.SetSpan(p.spanFrom(start))
+		}
+	}
+	return expression, nil
+}
+
 func (p *Parser) peekToken() token.Token {
-	if p.curPos >= len(p.tokens)-1 {
+	return p.tokenAt(1)
+}
+
+// onSameLine 判断当前token是否和上一个token在同一行,
+// 换行后的 ( 和 [ 会开始一条新的语句, 而不是调用或下标
+func (p *Parser) onSameLine() bool {
+	if p.curPos == 0 {
+		return true
+	}
+	return p.tokens[p.curPos].GetSpan().Start.Line == p.tokens[p.curPos-1].GetSpan().End.Line
+}
+
+// tokenAt 返回当前位置之后第offset个token, 超出范围时返回EOF
+func (p *Parser) tokenAt(offset int) token.Token {
+	if p.curPos+offset >= len(p.tokens) {
 		return token.New(token.EOF, "")
 	}
-	return p.tokens[p.curPos+1]
+	return p.tokens[p.curPos+offset]
+}
+
+// parseCondition 解析if、while中的条件, 条件中的 Name {} 不会被当作结构体字面值
+func (p *Parser) parseCondition() (ast.Expression, error) {
+	defer p.allowStructLiteral(false)()
+	return p.parseExpression()
+}
+
+// allowStructLiteral 设置是否允许 Name {} 形式的结构体字面值, 返回用于恢复原状态的函数
+func (p *Parser) allowStructLiteral(allow bool) func() {
+	outer := p.noStructLiteral
+	p.noStructLiteral = !allow
+	return func() {
+		p.noStructLiteral = outer
+	}
+}
+
+// spanFrom 返回从start开始, 到上一个已读取token结束的区间
+func (p *Parser) spanFrom(start token.Token) token.Span {
+	end := start.GetSpan().End
+	if p.curPos > 0 && p.curPos <= len(p.tokens) {
+		end = p.tokens[p.curPos-1].GetSpan().End
+	}
+	return token.Span{Start: start.GetSpan().Start, End: end}
+}
+
+// errorf 返回一个位于tok处的语法错误
+func (p *Parser) errorf(tok token.Token, format string, args ...interface{}) error {
+	return diagnostics.Errorf(tok.GetSpan(), diagnostics.SyntaxError, format, args...)
+}
+
+// unexpected 返回当前token与期望不符的错误
+func (p *Parser) unexpected(expected string) error {
+	tok := p.tokens[p.curPos]
+	return p.errorf(tok, "invalid token type, expected %s, but got %v", expected, describeToken(tok))
+}
+
+// describeToken 返回错误信息中使用的token, 文件末尾没有字面值, 显示为 end of file
+func describeToken(tok token.Token) string {
+	if tok.GetType() == token.EOF {
+		return "end of file"
+	}
+	return tok.GetLiteral()
 }
@@ -2,9 +2,11 @@ package parser
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/bootun/mini-tun/pkg/ast"
+	"github.com/bootun/mini-tun/pkg/diagnostics"
 	"github.com/bootun/mini-tun/pkg/lexer"
 	"github.com/bootun/mini-tun/pkg/token"
 )
@@ -95,9 +97,9 @@ let c = add(a, add(a,10))
 					),
 					ast.NewVariableAssignment("a", ast.NewLiteralExpression(1)),
 					ast.NewVariableAssignment("b", ast.NewLiteralExpression(2)),
-					ast.NewVariableAssignment("c", ast.NewFunctionCall("add", []ast.Expression{
+					ast.NewVariableAssignment("c", ast.NewFunctionCall(ast.NewIdentifierExpression("add"), []ast.Expression{
 						ast.NewIdentifierExpression("a"),
-						ast.NewFunctionCall("add", []ast.Expression{
+						ast.NewFunctionCall(ast.NewIdentifierExpression("add"), []ast.Expression{
 							ast.NewIdentifierExpression("a"),
 							ast.NewLiteralExpression(10),
 						}),
@@ -106,23 +108,921 @@ let c = add(a, add(a,10))
 			},
 			wantErr: false,
 		},
+		{
+			name: "left_associative_expression",
+			fields: fields{
+				"let a = b - c + d",
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewVariableAssignment("a",
+						ast.NewComplexExpression(
+							ast.NewComplexExpression(
+								ast.NewIdentifierExpression("b"),
+								token.New(token.MINUS, "-"),
+								ast.NewIdentifierExpression("c"),
+							),
+							token.New(token.PLUS, "+"),
+							ast.NewIdentifierExpression("d"),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "operator_precedence_expression",
+			fields: fields{
+				"let a = 1 + 2 * 3 % 4",
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewVariableAssignment("a",
+						ast.NewComplexExpression(
+							ast.NewLiteralExpression(1),
+							token.New(token.PLUS, "+"),
+							ast.NewComplexExpression(
+								ast.NewComplexExpression(
+									ast.NewLiteralExpression(2),
+									token.New(token.ASTERISK, "*"),
+									ast.NewLiteralExpression(3),
+								),
+								token.New(token.PERCENT, "%"),
+								ast.NewLiteralExpression(4),
+							),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "grouped_expression",
+			fields: fields{
+				"let a = (1 + add(b, 2)) / c",
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewVariableAssignment("a",
+						ast.NewComplexExpression(
+							ast.NewComplexExpression(
+								ast.NewLiteralExpression(1),
+								token.New(token.PLUS, "+"),
+								ast.NewFunctionCall(ast.NewIdentifierExpression("add"), []ast.Expression{
+									ast.NewIdentifierExpression("b"),
+									ast.NewLiteralExpression(2),
+								}),
+							),
+							token.New(token.SLASH, "/"),
+							ast.NewIdentifierExpression("c"),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "prefix_expression",
+			fields: fields{
+				"let a = -(-x) * +2 - !b",
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewVariableAssignment("a",
+						ast.NewComplexExpression(
+							ast.NewComplexExpression(
+								ast.NewPrefixExpression(
+									token.New(token.MINUS, "-"),
+									ast.NewPrefixExpression(
+										token.New(token.MINUS, "-"),
+										ast.NewIdentifierExpression("x"),
+									),
+								),
+								token.New(token.ASTERISK, "*"),
+								ast.NewPrefixExpression(
+									token.New(token.PLUS, "+"),
+									ast.NewLiteralExpression(2),
+								),
+							),
+							token.New(token.MINUS, "-"),
+							ast.NewPrefixExpression(
+								token.New(token.BANG, "!"),
+								ast.NewIdentifierExpression("b"),
+							),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "logical_and_comparison_expression",
+			fields: fields{
+				"let a = x < 1 + 2 || y == z && true",
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewVariableAssignment("a",
+						ast.NewLogicalExpression(
+							ast.NewComplexExpression(
+								ast.NewIdentifierExpression("x"),
+								token.New(token.LT, "<"),
+								ast.NewComplexExpression(
+									ast.NewLiteralExpression(1),
+									token.New(token.PLUS, "+"),
+									ast.NewLiteralExpression(2),
+								),
+							),
+							token.New(token.OR, "||"),
+							ast.NewLogicalExpression(
+								ast.NewComplexExpression(
+									ast.NewIdentifierExpression("y"),
+									token.New(token.EQ, "=="),
+									ast.NewIdentifierExpression("z"),
+								),
+								token.New(token.AND, "&&"),
+								ast.NewBooleanLiteral(true),
+							),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "if_else_statement",
+			fields: fields{
+				`
+if a < 1 {
+	let b = 1
+} else if a < 2 {
+	return 2
+} else {
+	return 3
+}`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewIfStatement(
+						ast.NewComplexExpression(
+							ast.NewIdentifierExpression("a"),
+							token.New(token.LT, "<"),
+							ast.NewLiteralExpression(1),
+						),
+						ast.NewBlockStatement([]ast.Statement{
+							ast.NewVariableAssignment("b", ast.NewLiteralExpression(1)),
+						}),
+						ast.NewIfStatement(
+							ast.NewComplexExpression(
+								ast.NewIdentifierExpression("a"),
+								token.New(token.LT, "<"),
+								ast.NewLiteralExpression(2),
+							),
+							ast.NewBlockStatement([]ast.Statement{
+								ast.NewReturnStatement(ast.NewLiteralExpression(2)),
+							}),
+							ast.NewBlockStatement([]ast.Statement{
+								ast.NewReturnStatement(ast.NewLiteralExpression(3)),
+							}),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unclosed_if_block",
+			fields: fields{
+				"if a { let b = 1",
+			},
+			want:    ast.Program{},
+			wantErr: true,
+		},
+		{
+			name: "loop_statement",
+			fields: fields{
+				`
+for let i = 0; i < n; i = i + 1 {
+	while i > 0 {
+		break
+	}
+	continue
+}
+for ; ; {
+}`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewForStatement(
+						ast.NewVariableAssignment("i", ast.NewLiteralExpression(0)),
+						ast.NewComplexExpression(
+							ast.NewIdentifierExpression("i"),
+							token.New(token.LT, "<"),
+							ast.NewIdentifierExpression("n"),
+						),
+						ast.NewAssignment("i", token.New(token.EQUAL, "="), ast.NewComplexExpression(
+							ast.NewIdentifierExpression("i"),
+							token.New(token.PLUS, "+"),
+							ast.NewLiteralExpression(1),
+						)),
+						ast.NewBlockStatement([]ast.Statement{
+							ast.NewWhileStatement(
+								ast.NewComplexExpression(
+									ast.NewIdentifierExpression("i"),
+									token.New(token.GT, ">"),
+									ast.NewLiteralExpression(0),
+								),
+								ast.NewBlockStatement([]ast.Statement{
+									ast.NewBreakStatement(),
+								}),
+							),
+							ast.NewContinueStatement(),
+						}),
+					),
+					ast.NewForStatement(nil, nil, nil, ast.NewBlockStatement(nil)),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "assignment_statement",
+			fields: fields{
+				`
+x = 1
+x += y * 2
+x -= 1
+x *= 2
+x /= 3`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewAssignment("x", token.New(token.EQUAL, "="), ast.NewLiteralExpression(1)),
+					ast.NewAssignment("x", token.New(token.PLUS_ASSIGN, "+="), ast.NewComplexExpression(
+						ast.NewIdentifierExpression("y"),
+						token.New(token.ASTERISK, "*"),
+						ast.NewLiteralExpression(2),
+					)),
+					ast.NewAssignment("x", token.New(token.MINUS_ASSIGN, "-="), ast.NewLiteralExpression(1)),
+					ast.NewAssignment("x", token.New(token.ASTERISK_ASSIGN, "*="), ast.NewLiteralExpression(2)),
+					ast.NewAssignment("x", token.New(token.SLASH_ASSIGN, "/="), ast.NewLiteralExpression(3)),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "expression_statement",
+			fields: fields{
+				`
+log(x)
+add(1, 2) * 3`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewExpressionStatement(
+						ast.NewFunctionCall(ast.NewIdentifierExpression("log"), []ast.Expression{
+							ast.NewIdentifierExpression("x"),
+						}),
+					),
+					ast.NewExpressionStatement(
+						ast.NewComplexExpression(
+							ast.NewFunctionCall(ast.NewIdentifierExpression("add"), []ast.Expression{
+								ast.NewLiteralExpression(1),
+								ast.NewLiteralExpression(2),
+							}),
+							token.New(token.ASTERISK, "*"),
+							ast.NewLiteralExpression(3),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "function_declaration",
+			fields: fields{
+				`
+function inc(x) {
+	return x + 1
+}`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewFunctionDeclaration("inc",
+						ast.NewFunctionLiteral(
+							[]*ast.IdentifierExpression{
+								ast.NewIdentifierExpression("x"),
+							},
+							ast.NewBlockStatement([]ast.Statement{
+								ast.NewReturnStatement(
+									ast.NewComplexExpression(
+										ast.NewIdentifierExpression("x"),
+										token.New(token.PLUS, "+"),
+										ast.NewLiteralExpression(1),
+									),
+								),
+							}),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "string_interpolation",
+			fields: fields{
+				`let s = "a${x + 1}b${y}"`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewVariableAssignment("s",
+						ast.NewComplexExpression(
+							ast.NewComplexExpression(
+								ast.NewComplexExpression(
+									ast.NewStringLiteral("a"),
+									token.New(token.PLUS, "+"),
+									ast.NewComplexExpression(
+										ast.NewIdentifierExpression("x"),
+										token.New(token.PLUS, "+"),
+										ast.NewLiteralExpression(1),
+									),
+								),
+								token.New(token.PLUS, "+"),
+								ast.NewStringLiteral("b"),
+							),
+							token.New(token.PLUS, "+"),
+							ast.NewIdentifierExpression("y"),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "numeric_literals",
+			fields: fields{
+				"let a = 0xff + 1_000 * 2.5e-1",
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewVariableAssignment("a",
+						ast.NewComplexExpression(
+							ast.NewLiteralExpression(255),
+							token.New(token.PLUS, "+"),
+							ast.NewComplexExpression(
+								ast.NewLiteralExpression(1000),
+								token.New(token.ASTERISK, "*"),
+								ast.NewFloatLiteral(0.25),
+							),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "array_index_and_slice",
+			fields: fields{
+				`
+let a = [1, 2 + 3, [4],]
+a[0] += -a[2][0]
+let b = a[1:] + a[:len(a)]`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewVariableAssignment("a",
+						ast.NewArrayLiteral([]ast.Expression{
+							ast.NewLiteralExpression(1),
+							ast.NewComplexExpression(
+								ast.NewLiteralExpression(2),
+								token.New(token.PLUS, "+"),
+								ast.NewLiteralExpression(3),
+							),
+							ast.NewArrayLiteral([]ast.Expression{
+								ast.NewLiteralExpression(4),
+							}),
+						}),
+					),
+					ast.NewIndexAssignment(
+						ast.NewIndexExpression(ast.NewIdentifierExpression("a"), ast.NewLiteralExpression(0)),
+						token.New(token.PLUS_ASSIGN, "+="),
+						ast.NewPrefixExpression(
+							token.New(token.MINUS, "-"),
+							ast.NewIndexExpression(
+								ast.NewIndexExpression(ast.NewIdentifierExpression("a"), ast.NewLiteralExpression(2)),
+								ast.NewLiteralExpression(0),
+							),
+						),
+					),
+					ast.NewVariableAssignment("b",
+						ast.NewComplexExpression(
+							ast.NewSliceExpression(ast.NewIdentifierExpression("a"), ast.NewLiteralExpression(1), nil),
+							token.New(token.PLUS, "+"),
+							ast.NewSliceExpression(ast.NewIdentifierExpression("a"), nil,
+								ast.NewFunctionCall(ast.NewIdentifierExpression("len"), []ast.Expression{
+									ast.NewIdentifierExpression("a"),
+								}),
+							),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "map_literal_and_block",
+			fields: fields{
+				`
+let m = {"a": 1, 2: {},}
+{
+    m["b"] = m["a"]
+}`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewVariableAssignment("m",
+						ast.NewMapLiteral([]*ast.MapPair{
+							{Key: ast.NewStringLiteral("a"), Value: ast.NewLiteralExpression(1)},
+							{Key: ast.NewLiteralExpression(2), Value: ast.NewMapLiteral([]*ast.MapPair{})},
+						}),
+					),
+					ast.NewBlockStatement([]ast.Statement{
+						ast.NewIndexAssignment(
+							ast.NewIndexExpression(ast.NewIdentifierExpression("m"), ast.NewStringLiteral("b")),
+							token.New(token.EQUAL, "="),
+							ast.NewIndexExpression(ast.NewIdentifierExpression("m"), ast.NewStringLiteral("a")),
+						),
+					}),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "map_missing_colon",
+			fields: fields{
+				`let m = {"a" 1}`,
+			},
+			want:    ast.Program{},
+			wantErr: true,
+		},
+		{
+			name: "struct_declaration_and_literal",
+			fields: fields{
+				`
+struct Point { x, y, }
+let p = Point{x: 1, y: Point{x: 2, y: 3}.x}
+p.y.z -= 1
+if ok {}`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewStructDecl("Point", []*ast.IdentifierExpression{
+						ast.NewIdentifierExpression("x"),
+						ast.NewIdentifierExpression("y"),
+					}),
+					ast.NewVariableAssignment("p",
+						ast.NewStructLiteral("Point", []*ast.StructField{
+							{Name: "x", Value: ast.NewLiteralExpression(1)},
+							{Name: "y", Value: ast.NewFieldAccess(
+								ast.NewStructLiteral("Point", []*ast.StructField{
+									{Name: "x", Value: ast.NewLiteralExpression(2)},
+									{Name: "y", Value: ast.NewLiteralExpression(3)},
+								}),
+								"x",
+							)},
+						}),
+					),
+					ast.NewFieldAssignment(
+						ast.NewFieldAccess(ast.NewFieldAccess(ast.NewIdentifierExpression("p"), "y"), "z"),
+						token.New(token.MINUS_ASSIGN, "-="),
+						ast.NewLiteralExpression(1),
+					),
+					ast.NewIfStatement(ast.NewIdentifierExpression("ok"), ast.NewBlockStatement(nil), nil),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "nested_struct_declaration",
+			fields: fields{
+				`
+if true {
+    struct Point { x }
+}`,
+			},
+			want:    ast.Program{},
+			wantErr: true,
+		},
+		{
+			name: "chained_calls",
+			fields: fields{
+				`
+makeAdder(1)(2)
+(function(x) { return x })(3)
+ops[0](a, b,)`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewExpressionStatement(
+						ast.NewFunctionCall(
+							ast.NewFunctionCall(ast.NewIdentifierExpression("makeAdder"), []ast.Expression{
+								ast.NewLiteralExpression(1),
+							}),
+							[]ast.Expression{ast.NewLiteralExpression(2)},
+						),
+					),
+					ast.NewExpressionStatement(
+						ast.NewFunctionCall(
+							ast.NewFunctionLiteral(
+								[]*ast.IdentifierExpression{ast.NewIdentifierExpression("x")},
+								ast.NewBlockStatement([]ast.Statement{
+									ast.NewReturnStatement(ast.NewIdentifierExpression("x")),
+								}),
+							),
+							[]ast.Expression{ast.NewLiteralExpression(3)},
+						),
+					),
+					ast.NewExpressionStatement(
+						ast.NewFunctionCall(
+							ast.NewIndexExpression(ast.NewIdentifierExpression("ops"), ast.NewLiteralExpression(0)),
+							[]ast.Expression{
+								ast.NewIdentifierExpression("a"),
+								ast.NewIdentifierExpression("b"),
+							},
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "type_annotations",
+			fields: fields{
+				`
+struct Point { x: float, y }
+let m: map[string][]int = {}
+let f = function(a: int, g: function(int) bool): bool {
+	return g(a)
+}`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewStructDecl("Point", []*ast.IdentifierExpression{
+						withType(ast.NewIdentifierExpression("x"), ast.NewNamedType("float")),
+						ast.NewIdentifierExpression("y"),
+					}),
+					typedLet("m",
+						ast.NewMapType(ast.NewNamedType("string"), ast.NewArrayType(ast.NewNamedType("int"))),
+						ast.NewMapLiteral([]*ast.MapPair{}),
+					),
+					ast.NewVariableAssignment("f",
+						withReturnType(
+							ast.NewFunctionLiteral(
+								[]*ast.IdentifierExpression{
+									withType(ast.NewIdentifierExpression("a"), ast.NewNamedType("int")),
+									withType(ast.NewIdentifierExpression("g"), ast.NewFunctionType(
+										[]ast.TypeExpression{ast.NewNamedType("int")},
+										ast.NewNamedType("bool"),
+									)),
+								},
+								ast.NewBlockStatement([]ast.Statement{
+									ast.NewReturnStatement(
+										ast.NewFunctionCall(ast.NewIdentifierExpression("g"), []ast.Expression{
+											ast.NewIdentifierExpression("a"),
+										}),
+									),
+								}),
+							),
+							ast.NewNamedType("bool"),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "function_type_without_result",
+			fields: fields{
+				"let f: function(int, string) = function(a, b) {}",
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					typedLet("f",
+						ast.NewFunctionType([]ast.TypeExpression{ast.NewNamedType("int"), ast.NewNamedType("string")}, nil),
+						ast.NewFunctionLiteral(
+							[]*ast.IdentifierExpression{
+								ast.NewIdentifierExpression("a"),
+								ast.NewIdentifierExpression("b"),
+							},
+							ast.NewBlockStatement(nil),
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "type_parameters",
+			fields: fields{
+				`
+function id<T>(x: T): T { return x }
+let pair = function<A, B>(a: A, b: B) {}`,
+			},
+			want: ast.Program{
+				Statements: []ast.Statement{
+					ast.NewFunctionDeclaration("id",
+						withTypeParameters(
+							withReturnType(
+								ast.NewFunctionLiteral(
+									[]*ast.IdentifierExpression{
+										withType(ast.NewIdentifierExpression("x"), ast.NewNamedType("T")),
+									},
+									ast.NewBlockStatement([]ast.Statement{
+										ast.NewReturnStatement(ast.NewIdentifierExpression("x")),
+									}),
+								),
+								ast.NewNamedType("T"),
+							),
+							"T",
+						),
+					),
+					ast.NewVariableAssignment("pair",
+						withTypeParameters(
+							ast.NewFunctionLiteral(
+								[]*ast.IdentifierExpression{
+									withType(ast.NewIdentifierExpression("a"), ast.NewNamedType("A")),
+									withType(ast.NewIdentifierExpression("b"), ast.NewNamedType("B")),
+								},
+								ast.NewBlockStatement(nil),
+							),
+							"A", "B",
+						),
+					),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "empty_type_parameters",
+			fields: fields{
+				"let f = function<>(x) {}",
+			},
+			want:    ast.Program{},
+			wantErr: true,
+		},
+		{
+			name: "missing_type_after_colon",
+			fields: fields{
+				"let a: = 1",
+			},
+			want:    ast.Program{},
+			wantErr: true,
+		},
+		{
+			name: "missing_comma_between_arguments",
+			fields: fields{
+				"f(1 2)",
+			},
+			want:    ast.Program{},
+			wantErr: true,
+		},
+		{
+			name: "assign_to_non_index_expression",
+			fields: fields{
+				"a + 1 = 2",
+			},
+			want:    ast.Program{},
+			wantErr: true,
+		},
+		{
+			name: "unclosed_bracket",
+			fields: fields{
+				"let a = [1, 2",
+			},
+			want:    ast.Program{},
+			wantErr: true,
+		},
+		{
+			name: "unclosed_parenthesis",
+			fields: fields{
+				"let a = (1 + 2",
+			},
+			want:    ast.Program{},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			lex := lexer.New(tt.fields.input)
-			p, err := New(lex)
-			if err != nil {
-				t.Errorf("New() error = %v", err)
-				return
-			}
-			got, err := p.Parse()
+			got, err := New(lex).Parse()
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				return
+			}
+			stripSpans(reflect.ValueOf(&got))
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Parse() got = %v, want %v", got.JSON(), tt.want)
 			}
 		})
 	}
 }
+
+// withType 给标识符加上类型标注, 用于构造期望的语法树
+func withType(identifier *ast.IdentifierExpression, typ ast.TypeExpression) *ast.IdentifierExpression {
+	identifier.Type = typ
+	return identifier
+}
+
+// withReturnType 给函数加上返回值的类型标注, 用于构造期望的语法树
+func withReturnType(function *ast.FunctionLiteral, typ ast.TypeExpression) *ast.FunctionLiteral {
+	function.ReturnType = typ
+	return function
+}
+
+// withTypeParameters 给函数加上类型参数, 用于构造期望的语法树
+func withTypeParameters(function *ast.FunctionLiteral, names ...string) *ast.FunctionLiteral {
+	for _, name := range names {
+		function.TypeParameters = append(function.TypeParameters, ast.NewIdentifierExpression(name))
+	}
+	return function
+}
+
+// typedLet 构造带有类型标注的变量声明
+func typedLet(name string, typ ast.TypeExpression, value ast.Expression) *ast.VariableAssignment {
+	statement := ast.NewVariableAssignment(name, value)
+	statement.Type = typ
+	return statement
+}
+
+var spanType = reflect.TypeOf(token.Span{})
+
+// stripSpans 递归地清空语法树中的位置信息, 方便只比较树的结构
+func stripSpans(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			stripSpans(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			stripSpans(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == spanType {
+			if v.CanSet() {
+				v.Set(reflect.Zero(spanType))
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			stripSpans(v.Field(i))
+		}
+	}
+}
+
+func TestParser_Span(t *testing.T) {
+	input := `let a = 1
+let add = function(x, y) {
+	return x + y
+}
+let b = add(a, 2)`
+	program, err := New(lexer.New(input)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	tests := []struct {
+		node  ast.Node
+		start string
+		end   string
+	}{
+		{program.Statements[0], "1:1", "1:10"},
+		{program.Statements[1], "2:1", "4:2"},
+		{program.Statements[1].(*ast.VariableAssignment).Value, "2:11", "4:2"},
+		{program.Statements[2], "5:1", "5:18"},
+		{program.Statements[2].(*ast.VariableAssignment).Value, "5:9", "5:18"},
+	}
+	for _, tt := range tests {
+		span := tt.node.GetSpan()
+		if span.Start.String() != tt.start || span.End.String() != tt.end {
+			t.Errorf("%s span = %v-%v, want %s-%s", tt.node.TokenLiteral(), span.Start, span.End, tt.start, tt.end)
+		}
+	}
+}
+
+func TestParser_ErrorPosition(t *testing.T) {
+	_, err := New(lexer.New("let a = 1\nlet = 2", lexer.WithFilename("bad.tun"))).Parse()
+	if err == nil || !strings.Contains(err.Error(), "bad.tun:2:5") {
+		t.Errorf("Parse() error = %v, want position bad.tun:2:5", err)
+	}
+}
+
+func TestParser_Recovery(t *testing.T) {
+	input := `let a = 1
+let = 2
+function f() {
+	let x = )
+	return x
+}
+let b = [1, 2
+let c = 3`
+	program, err := New(lexer.New(input)).Parse()
+	list := diagnostics.FromError(err)
+	var got []string
+	for _, d := range list {
+		got = append(got, d.Error())
+	}
+	want := []string{
+		"2:5: invalid token type, expected identifier, but got =",
+		"4:10: unexpected token type: )",
+		"8:1: invalid token type, expected comma or right bracket, but got let",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() errors = %q, want %q", got, want)
+	}
+	for _, d := range list {
+		if d.Code != diagnostics.SyntaxError {
+			t.Errorf("Parse() error code = %s, want %s", d.Code, diagnostics.SyntaxError)
+		}
+	}
+	// 出错的语句被跳过, 函数体中出错的语句不影响函数中的其他语句, 缺少右括号的语句不影响下一行的语句
+	var statements []string
+	for _, stmt := range program.Statements {
+		statements = append(statements, stmt.TokenLiteral())
+	}
+	wantStatements := []string{"let a = 1", "function f() {return x;}", "let c = 3"}
+	if !reflect.DeepEqual(statements, wantStatements) {
+		t.Errorf("Parse() statements = %q, want %q", statements, wantStatements)
+	}
+}
+
+func TestParser_LexerErrors(t *testing.T) {
+	input := `let a = 1 @
+let b = )
+let c = 3`
+	program, err := New(lexer.New(input)).Parse()
+	list := diagnostics.FromError(err)
+	var got []string
+	for _, d := range list {
+		got = append(got, string(d.Code)+" "+d.Error())
+	}
+	// 无法识别的字符只由词法分析报告一次, 之后的语法错误仍然被报告
+	want := []string{
+		"invalid-token 1:11: illegal character '@'",
+		"syntax 2:9: unexpected token type: )",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() errors = %q, want %q", got, want)
+	}
+	var statements []string
+	for _, stmt := range program.Statements {
+		statements = append(statements, stmt.TokenLiteral())
+	}
+	wantStatements := []string{"let a = 1", "let c = 3"}
+	if !reflect.DeepEqual(statements, wantStatements) {
+		t.Errorf("Parse() statements = %q, want %q", statements, wantStatements)
+	}
+}
+
+func TestParser_FunctionParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "missing_comma",
+			input:   `let f = function(a b) { return a + b }`,
+			wantErr: "1:20: invalid token type, expected comma or right parenthesis, but got b",
+		},
+		{
+			name:    "unterminated_parameters",
+			input:   `let f = function(a`,
+			wantErr: "1:19: invalid token type, expected comma or right parenthesis, but got end of file",
+		},
+		{
+			name:    "parameter_is_not_identifier",
+			input:   `let f = function(a, 1) {}`,
+			wantErr: "1:21: invalid token type, expected identifier, but got 1",
+		},
+		{
+			name:  "typed_parameters",
+			input: `let f = function(a: int, b) { return a + b }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(lexer.New(tt.input)).Parse()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Parse() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
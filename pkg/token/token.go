@@ -1,7 +1,10 @@
 package token
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 )
 
 type TokenType string
@@ -9,29 +12,107 @@ type TokenType string
 const (
 	// 特殊
 	EOF        TokenType = "EOF"
+	ILLEGAL    TokenType = "ILLEGAL"    // 无法识别的字符
 	IDENTIFIER TokenType = "IDENTIFIER" // 标识符
 	FUNCTION   TokenType = "FUNCTION"   // function
 	LET        TokenType = "LET"        // let
 	RETURN     TokenType = "RETURN"     // return
+	TRUE       TokenType = "TRUE"       // true
+	FALSE      TokenType = "FALSE"      // false
+	IF         TokenType = "IF"         // if
+	ELSE       TokenType = "ELSE"       // else
+	WHILE      TokenType = "WHILE"      // while
+	FOR        TokenType = "FOR"        // for
+	BREAK      TokenType = "BREAK"      // break
+	CONTINUE   TokenType = "CONTINUE"   // continue
+	STRUCT     TokenType = "STRUCT"     // struct
 	EQUAL      TokenType = "EQUAL"      // =
 	LPAREN     TokenType = "LPAREN"     // (
 	RPAREN     TokenType = "RPAREN"     // )
 	LBRACE     TokenType = "LBRACE"     // {
 	RBRACE     TokenType = "RBRACE"     // }
+	LBRACKET   TokenType = "LBRACKET"   // [
+	RBRACKET   TokenType = "RBRACKET"   // ]
 	COMMA      TokenType = "COMMA"      // ,
-	COMMENT    TokenType = "COMMENT"    // //
+	COLON      TokenType = "COLON"      // :
+	DOT        TokenType = "DOT"        // .
+	SEMICOLON  TokenType = "SEMICOLON"  // ;
+	COMMENT    TokenType = "COMMENT"    // // 或 /* */
 
 	// 操作符
-	PLUS  TokenType = "PLUS"  // +
-	MINUS TokenType = "MINUS" // -
+	PLUS     TokenType = "PLUS"     // +
+	MINUS    TokenType = "MINUS"    // -
+	ASTERISK TokenType = "ASTERISK" // *
+	SLASH    TokenType = "SLASH"    // /
+	PERCENT  TokenType = "PERCENT"  // %
+	BANG     TokenType = "BANG"     // !
+	EQ       TokenType = "EQ"       // ==
+	NOT_EQ   TokenType = "NOT_EQ"   // !=
+	LT       TokenType = "LT"       // <
+	LTE      TokenType = "LTE"      // <=
+	GT       TokenType = "GT"       // >
+	GTE      TokenType = "GTE"      // >=
+	AND      TokenType = "AND"      // &&
+	OR       TokenType = "OR"       // ||
+
+	// 复合赋值
+	PLUS_ASSIGN     TokenType = "PLUS_ASSIGN"     // +=
+	MINUS_ASSIGN    TokenType = "MINUS_ASSIGN"    // -=
+	ASTERISK_ASSIGN TokenType = "ASTERISK_ASSIGN" // *=
+	SLASH_ASSIGN    TokenType = "SLASH_ASSIGN"    // /=
 
 	// 类型
-	INT TokenType = "INT" // int
+	INT    TokenType = "INT"    // 1, 0xff, 0o17, 0b1010, 1_000
+	FLOAT  TokenType = "FLOAT"  // 1.5, 1e10, 2.5e-3
+	STRING TokenType = "STRING" // "abc", 字面值为转义后的内容
+
+	// 字符串插值 "a${x}b" 会被拆分为 STRING INTERPOLATION_START x INTERPOLATION_END STRING
+	INTERPOLATION_START TokenType = "INTERPOLATION_START" // ${
+	INTERPOLATION_END   TokenType = "INTERPOLATION_END"   // }
 )
 
+// Position 表示源码中的一个位置
+type Position struct {
+	Filename string // 文件名, 可以为空
+	Offset   int    // 字节偏移量, 从0开始
+	Line     int    // 行号, 从1开始
+	Column   int    // 列号(按字节计算), 从1开始
+}
+
+// IsValid 判断位置是否有效, 零值表示未知位置
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String 返回 file:line:col 形式的位置, 没有文件名时返回 line:col
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// Span 表示源码中的一段区间, End 指向区间最后一个字符的下一个位置
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) String() string {
+	return s.Start.String()
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	Span    Span
 }
 
 func (t Token) GetType() TokenType {
@@ -42,27 +123,112 @@ func (t Token) GetLiteral() string {
 	return t.Literal
 }
 
+func (t Token) GetSpan() Span {
+	return t.Span
+}
+
 func New(tokenType TokenType, literal string) Token {
 	return Token{Type: tokenType, Literal: literal}
 }
 
+func NewWithSpan(tokenType TokenType, literal string, span Span) Token {
+	return Token{Type: tokenType, Literal: literal, Span: span}
+}
+
 var keywords = map[string]TokenType{
 	"function": FUNCTION,
 	"let":      LET,
 	"return":   RETURN,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"struct":   STRUCT,
 }
 
 func LookupIdent(ident string) TokenType {
 	if tok, ok := keywords[ident]; ok {
 		return tok
 	}
-	if isInt(ident) {
-		return INT
-	}
 	return IDENTIFIER
 }
 
-func isInt(ident string) bool {
-	_, err := strconv.ParseInt(ident, 10, 64)
-	return err == nil
+// ParseInt 解析整数字面值, 支持 0x、0o、0b 前缀以及数字间的 _ 分隔符
+func ParseInt(literal string) (int64, error) {
+	base, digits := 10, literal
+	if len(literal) > 2 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			digits = literal[2:]
+		}
+	}
+	digits, ok := removeSeparators(digits, base == 16)
+	if !ok {
+		return 0, fmt.Errorf("invalid integer literal: %s", literal)
+	}
+	v, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, fmt.Errorf("integer literal out of range: %s", literal)
+		}
+		return 0, fmt.Errorf("invalid integer literal: %s", literal)
+	}
+	return v, nil
+}
+
+// ParseFloat 解析浮点数字面值, 如 1.5、2e10、1_000.5e-3
+func ParseFloat(literal string) (float64, error) {
+	digits, ok := removeSeparators(literal, false)
+	if !ok {
+		return 0, fmt.Errorf("invalid float literal: %s", literal)
+	}
+	v, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, fmt.Errorf("float literal out of range: %s", literal)
+		}
+		return 0, fmt.Errorf("invalid float literal: %s", literal)
+	}
+	return v, nil
+}
+
+// FormatFloat 格式化浮点数, 结果总是带有小数点或指数, 以便和整数区分, 如 1.0、1e+21
+func FormatFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// removeSeparators 去掉数字中的 _ 分隔符, 分隔符只能出现在两个数字之间
+func removeSeparators(literal string, hex bool) (string, bool) {
+	if !strings.Contains(literal, "_") {
+		return literal, true
+	}
+	isDigit := func(b byte) bool {
+		return (b >= '0' && b <= '9') || (hex && ((b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')))
+	}
+	var buf strings.Builder
+	for i := 0; i < len(literal); i++ {
+		if literal[i] == '_' {
+			if i == 0 || i == len(literal)-1 || !isDigit(literal[i-1]) || !isDigit(literal[i+1]) {
+				return "", false
+			}
+			continue
+		}
+		buf.WriteByte(literal[i])
+	}
+	return buf.String(), true
 }
@@ -0,0 +1,80 @@
+package typecheck
+
+import (
+	"github.com/bootun/mini-tun/pkg/ast"
+	"github.com/bootun/mini-tun/pkg/diagnostics"
+	"github.com/bootun/mini-tun/pkg/types"
+)
+
+// basicTypes 可以在类型标注中使用的基本类型
+var basicTypes = map[string]types.Type{
+	"int":    types.Int,
+	"float":  types.Float,
+	"bool":   types.Bool,
+	"string": types.String,
+}
+
+// resolveType 把类型标注转换为类型
+func (c *Checker) resolveType(expr ast.TypeExpression) (types.Type, error) {
+	switch node := expr.(type) {
+	case *ast.NamedType:
+		// 类型参数会遮蔽同名的类型
+		if typ, ok := c.typeParams[node.Name]; ok {
+			return typ, nil
+		}
+		if typ, ok := basicTypes[node.Name]; ok {
+			return typ, nil
+		}
+		if typ, ok := c.structs[node.Name]; ok {
+			return typ, nil
+		}
+		return nil, typeError(node, diagnostics.Undefined, "undefined type: %s", node.Name)
+	case *ast.ArrayType:
+		elem, err := c.resolveType(node.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &types.Array{Elem: elem}, nil
+	case *ast.MapType:
+		key, err := c.resolveType(node.Key)
+		if err != nil {
+			return nil, err
+		}
+		if !types.Restrict(key, types.MapKey) {
+			return nil, typeError(node.Key, diagnostics.TypeMismatch, "invalid map key type %s", describe(key))
+		}
+		value, err := c.resolveType(node.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &types.Map{Key: key, Value: value}, nil
+	case *ast.FunctionType:
+		params := make([]types.Type, 0, len(node.Params))
+		for _, param := range node.Params {
+			typ, err := c.resolveType(param)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, typ)
+		}
+		// 没有标注返回值类型的函数类型表示没有返回值的函数
+		var result types.Type = types.Void
+		if node.Result != nil {
+			typ, err := c.resolveType(node.Result)
+			if err != nil {
+				return nil, err
+			}
+			result = typ
+		}
+		return &types.Function{Params: params, Result: result}, nil
+	}
+	return nil, typeError(expr, diagnostics.InvalidStatement, "unsupported type expression: %T", expr)
+}
+
+// resolveOptionalType 把可以省略的类型标注转换为类型, 省略时返回一个新的类型变量, 由类型推断确定
+func (c *Checker) resolveOptionalType(expr ast.TypeExpression) (types.Type, error) {
+	if expr == nil {
+		return c.newVar(0), nil
+	}
+	return c.resolveType(expr)
+}
@@ -0,0 +1,38 @@
+package typecheck
+
+import (
+	"github.com/bootun/mini-tun/pkg/types"
+)
+
+// newBuiltinScope 创建保存内置函数类型的作用域, 它是全局作用域的上级作用域.
+// 内置函数的类型带有类型参数, 每次使用时都会替换为新的类型变量
+func (c *Checker) newBuiltinScope() *scope {
+	s := newScope(nil)
+
+	// int(x) 和 float(x) 接受任意数字
+	number := c.newVar(types.Numeric)
+	s.declareScheme("int", generic(function(types.Int, number), number))
+	number = c.newVar(types.Numeric)
+	s.declareScheme("float", generic(function(types.Float, number), number))
+
+	// len(x) 接受数组、字典或字符串
+	sized := c.newVar(types.Sized)
+	s.declareScheme("len", generic(function(types.Int, sized), sized))
+
+	key, value := c.newVar(types.MapKey), c.newVar(0)
+	m := &types.Map{Key: key, Value: value}
+	s.declareScheme("has", generic(function(types.Bool, m, key), key, value))
+
+	key, value = c.newVar(types.MapKey), c.newVar(0)
+	m = &types.Map{Key: key, Value: value}
+	s.declareScheme("keys", generic(function(&types.Array{Elem: key}, m), key, value))
+	return s
+}
+
+func function(result types.Type, params ...types.Type) *types.Function {
+	return &types.Function{Params: params, Result: result}
+}
+
+func generic(typ types.Type, vars ...*types.Var) *types.Scheme {
+	return &types.Scheme{Vars: vars, Type: typ}
+}
@@ -0,0 +1,165 @@
+package typecheck
+
+import (
+	"slices"
+
+	"github.com/bootun/mini-tun/pkg/ast"
+	"github.com/bootun/mini-tun/pkg/diagnostics"
+	"github.com/bootun/mini-tun/pkg/token"
+	"github.com/bootun/mini-tun/pkg/types"
+)
+
+// infer 推断表达式的类型
+func (c *Checker) infer(expr ast.Expression) (types.Type, error) {
+	switch node := expr.(type) {
+	case *ast.LiteralExpression:
+		return types.Int, nil
+	case *ast.FloatLiteral:
+		return types.Float, nil
+	case *ast.BooleanLiteral:
+		return types.Bool, nil
+	case *ast.StringLiteral:
+		return types.String, nil
+	case *ast.IdentifierExpression:
+		scheme, ok := c.env.lookup(node.Value)
+		if !ok {
+			return nil, typeError(node, diagnostics.Undefined, "undefined variable: %s", node.Value)
+		}
+		return c.instantiate(scheme), nil
+	case *ast.ComplexExpression:
+		left, err := c.infer(node.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := c.infer(node.Right)
+		if err != nil {
+			return nil, err
+		}
+		return c.binary(node, node.Operator.Type, left, right)
+	case *ast.LogicalExpression:
+		for _, operand := range []ast.Expression{node.Left, node.Right} {
+			typ, err := c.infer(operand)
+			if err != nil {
+				return nil, err
+			}
+			if !types.Unify(types.Bool, typ) {
+				return nil, mismatch(operand, types.Bool, typ)
+			}
+		}
+		return types.Bool, nil
+	case *ast.PrefixExpression:
+		right, err := c.infer(node.Right)
+		if err != nil {
+			return nil, err
+		}
+		return prefix(node, right)
+	case *ast.FunctionLiteral:
+		// 函数字面值也会被泛化, 每次使用时得到一个新的实例
+		scheme, err := c.checkPolymorphic(node, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		return c.instantiate(scheme), nil
+	case *ast.FunctionCall:
+		return c.inferCall(node)
+	case *ast.ArrayLiteral:
+		var elem types.Type = c.newVar(0)
+		for _, element := range node.Elements {
+			typ, err := c.infer(element)
+			if err != nil {
+				return nil, err
+			}
+			if !types.Unify(elem, typ) {
+				return nil, mismatch(element, elem, typ)
+			}
+		}
+		return &types.Array{Elem: elem}, nil
+	case *ast.IndexExpression:
+		return c.inferIndex(node)
+	case *ast.SliceExpression:
+		return c.inferSlice(node)
+	case *ast.MapLiteral:
+		return c.inferMapLiteral(node)
+	case *ast.StructLiteral:
+		return c.inferStructLiteral(node)
+	case *ast.FieldAccess:
+		return c.inferField(node)
+	}
+	return nil, typeError(expr, diagnostics.InvalidStatement, "unsupported expression type: %T", expr)
+}
+
+// inferCall 依次推断被调用的函数和参数的类型, 参数的类型必须和函数的参数类型相同
+func (c *Checker) inferCall(node *ast.FunctionCall) (types.Type, error) {
+	callee, err := c.infer(node.Callee)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]types.Type, 0, len(node.Arguments))
+	for _, arg := range node.Arguments {
+		typ, err := c.infer(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, typ)
+	}
+	switch function := types.Resolve(callee).(type) {
+	case *types.Function:
+		if err := checkArity(node, calleeName(node), len(function.Params)); err != nil {
+			return nil, err
+		}
+		for i, arg := range args {
+			if !types.Assign(function.Params[i], arg) {
+				return nil, mismatch(node.Arguments[i], function.Params[i], arg)
+			}
+		}
+		return function.Result, nil
+	case *types.Var:
+		// 还不知道被调用的值的类型, 根据调用推断出它的函数类型
+		result := c.newVar(0)
+		want := &types.Function{Params: args, Result: result}
+		if types.Unify(function, want) {
+			return result, nil
+		}
+		// 函数的类型不能包含自身, 如 x(x) 中x的参数类型就是x的类型
+		if slices.Contains(types.FreeVars(want), function) {
+			return nil, typeError(node, diagnostics.InvalidOperation, "cannot infer type of %s: its type would have to contain itself", calleeName(node))
+		}
+	}
+	return nil, typeError(node.Callee, diagnostics.InvalidOperation, "value is not callable: %s is %s", node.Callee.TokenLiteral(), describe(callee))
+}
+
+// calleeName 返回错误信息中使用的被调用函数的名字
+func calleeName(node *ast.FunctionCall) string {
+	switch callee := node.Callee.(type) {
+	case *ast.IdentifierExpression:
+		return callee.Value
+	case *ast.FunctionLiteral:
+		return "function literal"
+	}
+	return "function"
+}
+
+// checkArity 检查调用的参数个数是否和函数的参数个数一致
+func checkArity(call *ast.FunctionCall, name string, arity int) error {
+	switch {
+	case len(call.Arguments) < arity:
+		return typeError(call, diagnostics.ArgumentCount, "not enough arguments in call to %s: expected %d, but got %d", name, arity, len(call.Arguments))
+	case len(call.Arguments) > arity:
+		return typeError(call, diagnostics.ArgumentCount, "too many arguments in call to %s: expected %d, but got %d", name, arity, len(call.Arguments))
+	}
+	return nil
+}
+
+// prefix 返回前缀运算的结果类型, ! 只能用于bool, - 和 + 只能用于数字
+func prefix(node *ast.PrefixExpression, right types.Type) (types.Type, error) {
+	if node.Operator.Type == token.BANG {
+		if !types.Unify(types.Bool, right) {
+			return nil, typeError(node, diagnostics.InvalidOperation, "cannot apply ! to %s", describe(right))
+		}
+		return types.Bool, nil
+	}
+	if !types.Restrict(right, types.Numeric) {
+		return nil, typeError(node, diagnostics.InvalidOperation, "cannot apply %s to %s", node.Operator.Literal, describe(right))
+	}
+	return right, nil
+}
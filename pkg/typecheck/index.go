@@ -0,0 +1,118 @@
+package typecheck
+
+import (
+	"github.com/bootun/mini-tun/pkg/ast"
+	"github.com/bootun/mini-tun/pkg/diagnostics"
+	"github.com/bootun/mini-tun/pkg/types"
+)
+
+// inferIndex 返回数组元素或字典值的类型, 数组的下标必须是int, 字典的键必须和字典的键类型相同
+func (c *Checker) inferIndex(node *ast.IndexExpression) (types.Type, error) {
+	left, err := c.infer(node.Left)
+	if err != nil {
+		return nil, err
+	}
+	index, err := c.infer(node.Index)
+	if err != nil {
+		return nil, err
+	}
+	switch container := types.Resolve(left).(type) {
+	case *types.Array:
+		if !types.Unify(types.Int, index) {
+			return nil, typeError(node.Index, diagnostics.TypeMismatch, "invalid array index type %s", describe(index))
+		}
+		return container.Elem, nil
+	case *types.Map:
+		if !types.Assign(container.Key, index) {
+			return nil, typeError(node.Index, diagnostics.TypeMismatch, "invalid map key type %s", describe(index))
+		}
+		return container.Value, nil
+	case *types.Var:
+		// 不知道被取下标的是数组还是字典, 下标为字符串时当作字典, 否则当作数组
+		if !types.Restrict(index, types.MapKey) {
+			return nil, typeError(node.Index, diagnostics.TypeMismatch, "invalid index type %s", describe(index))
+		}
+		var elem types.Type = c.newVar(0)
+		var want types.Type = &types.Array{Elem: elem}
+		if types.Resolve(index) == types.String {
+			want = &types.Map{Key: types.String, Value: elem}
+		} else {
+			types.Unify(types.Int, index)
+		}
+		if types.Unify(container, want) {
+			return elem, nil
+		}
+	}
+	return nil, typeError(node, diagnostics.InvalidOperation, "cannot index %s", describe(left))
+}
+
+// inferSlice 返回切片表达式的类型, 只有数组可以切片, 切片的边界必须是int
+func (c *Checker) inferSlice(node *ast.SliceExpression) (types.Type, error) {
+	left, err := c.infer(node.Left)
+	if err != nil {
+		return nil, err
+	}
+	if !types.Unify(&types.Array{Elem: c.newVar(0)}, left) {
+		return nil, typeError(node, diagnostics.InvalidOperation, "cannot slice %s", describe(left))
+	}
+	for _, bound := range []ast.Expression{node.Low, node.High} {
+		if bound == nil {
+			continue
+		}
+		typ, err := c.infer(bound)
+		if err != nil {
+			return nil, err
+		}
+		if !types.Unify(types.Int, typ) {
+			return nil, typeError(bound, diagnostics.TypeMismatch, "invalid array index type %s", describe(typ))
+		}
+	}
+	return left, nil
+}
+
+// inferMapLiteral 推断字典字面值的类型, 所有值的类型必须相同.
+// 键可以同时使用int和string, 此时字典的键类型为 int | string
+func (c *Checker) inferMapLiteral(node *ast.MapLiteral) (types.Type, error) {
+	var key, value types.Type = c.newVar(types.MapKey), c.newVar(0)
+	for _, pair := range node.Pairs {
+		typ, err := c.infer(pair.Key)
+		if err != nil {
+			return nil, err
+		}
+		if !types.Restrict(typ, types.MapKey) {
+			return nil, typeError(pair.Key, diagnostics.TypeMismatch, "invalid map key type %s", describe(typ))
+		}
+		if !types.Assign(key, typ) {
+			// 键已经满足MapKey约束, 无法统一时一个是int, 另一个是string
+			key = types.Key
+		}
+		typ, err = c.infer(pair.Value)
+		if err != nil {
+			return nil, err
+		}
+		if !types.Unify(value, typ) {
+			return nil, mismatch(pair.Value, value, typ)
+		}
+	}
+	if err := checkDuplicateKeys(node); err != nil {
+		return nil, err
+	}
+	return &types.Map{Key: key, Value: value}, nil
+}
+
+// checkDuplicateKeys 检查字典字面值中是否有重复的字面值键
+func checkDuplicateKeys(node *ast.MapLiteral) error {
+	seen := make(map[string]ast.Expression)
+	for _, pair := range node.Pairs {
+		switch pair.Key.(type) {
+		case *ast.LiteralExpression, *ast.StringLiteral:
+			key := pair.Key.TokenLiteral()
+			if first, ok := seen[key]; ok {
+				return typeError(pair.Key, diagnostics.Redeclared, "duplicate key %s in map literal", key).
+					WithNote(first.GetSpan(), "first used here")
+			}
+			seen[key] = pair.Key
+		}
+	}
+	return nil
+}
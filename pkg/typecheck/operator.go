@@ -0,0 +1,86 @@
+package typecheck
+
+import (
+	"github.com/bootun/mini-tun/pkg/ast"
+	"github.com/bootun/mini-tun/pkg/diagnostics"
+	"github.com/bootun/mini-tun/pkg/token"
+	"github.com/bootun/mini-tun/pkg/types"
+)
+
+// binary 返回二元运算的结果类型, 两侧的类型不支持该运算时返回错误
+func (c *Checker) binary(node ast.Node, operator token.TokenType, left, right types.Type) (types.Type, error) {
+	left, right = types.Resolve(left), types.Resolve(right)
+	switch operator {
+	case token.PLUS:
+		// 字符串可以和任意基本类型拼接
+		if left == types.String || right == types.String {
+			if !types.Restrict(left, types.Primitive) || !types.Restrict(right, types.Primitive) {
+				return nil, operationError(node, operator, left, right)
+			}
+			return types.String, nil
+		}
+		return arithmetic(node, operator, left, right, types.Ordered)
+	case token.MINUS, token.ASTERISK:
+		return arithmetic(node, operator, left, right, types.Numeric)
+	case token.SLASH:
+		// 除法的结果总是float
+		if _, err := arithmetic(node, operator, left, right, types.Numeric); err != nil {
+			return nil, err
+		}
+		return types.Float, nil
+	case token.PERCENT:
+		if !types.Unify(types.Int, left) || !types.Unify(types.Int, right) {
+			return nil, operationError(node, operator, left, right)
+		}
+		return types.Int, nil
+	case token.LT, token.LTE, token.GT, token.GTE:
+		if _, err := arithmetic(node, operator, left, right, types.Ordered); err != nil {
+			return nil, err
+		}
+		return types.Bool, nil
+	case token.EQ, token.NOT_EQ:
+		// 只有基本类型可以比较
+		if _, err := arithmetic(node, operator, left, right, types.Primitive); err != nil {
+			return nil, err
+		}
+		return types.Bool, nil
+	}
+	return nil, operationError(node, operator, left, right)
+}
+
+// arithmetic 要求两侧的类型属于allowed并且相同, 返回它们的类型.
+// int和float可以混合运算, 此时结果为float
+func arithmetic(node ast.Node, operator token.TokenType, left, right types.Type, allowed types.Kind) (types.Type, error) {
+	if !types.Restrict(left, allowed) || !types.Restrict(right, allowed) {
+		return nil, operationError(node, operator, left, right)
+	}
+	if isNumber(left) && isNumber(right) && left != right {
+		return types.Float, nil
+	}
+	if !types.Unify(left, right) {
+		return nil, operationError(node, operator, left, right)
+	}
+	return left, nil
+}
+
+// isNumber 判断类型是否已经确定为int或float
+func isNumber(typ types.Type) bool {
+	return typ == types.Int || typ == types.Float
+}
+
+// operationError 返回二元运算两侧的类型不支持该运算的错误, 如 cannot add function and int
+func operationError(node ast.Node, operator token.TokenType, left, right types.Type) error {
+	switch operator {
+	case token.PLUS:
+		return typeError(node, diagnostics.InvalidOperation, "cannot add %s and %s", describe(left), describe(right))
+	case token.MINUS:
+		return typeError(node, diagnostics.InvalidOperation, "cannot subtract %s from %s", describe(right), describe(left))
+	case token.ASTERISK:
+		return typeError(node, diagnostics.InvalidOperation, "cannot multiply %s by %s", describe(left), describe(right))
+	case token.SLASH:
+		return typeError(node, diagnostics.InvalidOperation, "cannot divide %s by %s", describe(left), describe(right))
+	case token.PERCENT:
+		return typeError(node, diagnostics.InvalidOperation, "cannot take remainder of %s divided by %s", describe(left), describe(right))
+	}
+	return typeError(node, diagnostics.InvalidOperation, "cannot compare %s and %s", describe(left), describe(right))
+}
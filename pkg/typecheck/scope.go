@@ -0,0 +1,39 @@
+package typecheck
+
+import (
+	"github.com/bootun/mini-tun/pkg/types"
+)
+
+// scope 表示一个作用域, 保存该作用域中声明的名字的类型,
+// 查找名字时会沿着parent向外层作用域查找
+type scope struct {
+	names  map[string]*types.Scheme
+	parent *scope
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{
+		names:  make(map[string]*types.Scheme),
+		parent: parent,
+	}
+}
+
+// declare 在当前作用域中声明名字, 会遮蔽外层作用域的同名名字
+func (s *scope) declare(name string, typ types.Type) {
+	s.names[name] = types.Mono(typ)
+}
+
+// declareScheme 在当前作用域中声明类型带有类型参数的名字
+func (s *scope) declareScheme(name string, scheme *types.Scheme) {
+	s.names[name] = scheme
+}
+
+// lookup 从当前作用域开始由内向外查找名字
+func (s *scope) lookup(name string) (*types.Scheme, bool) {
+	for env := s; env != nil; env = env.parent {
+		if scheme, ok := env.names[name]; ok {
+			return scheme, true
+		}
+	}
+	return nil, false
+}
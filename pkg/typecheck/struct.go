@@ -0,0 +1,126 @@
+package typecheck
+
+import (
+	"strings"
+
+	"github.com/bootun/mini-tun/pkg/ast"
+	"github.com/bootun/mini-tun/pkg/diagnostics"
+	"github.com/bootun/mini-tun/pkg/types"
+)
+
+// declareStructs 声明程序中的所有结构体, 并检查结构体是否重复声明以及字段是否重复.
+// 没有类型标注的字段的类型在第一次使用时推断, 之后所有该结构体的值的字段都必须是这个类型
+func (c *Checker) declareStructs(statements []ast.Statement) {
+	declarations := make(map[string]*ast.StructDecl)
+	var order []*ast.StructDecl
+	for _, stmt := range statements {
+		declaration, ok := stmt.(*ast.StructDecl)
+		if !ok {
+			continue
+		}
+		if first, ok := declarations[declaration.Name]; ok {
+			c.report(typeError(declaration, diagnostics.Redeclared, "struct %s redeclared", declaration.Name).
+				WithNote(first.GetSpan(), "first declared here"))
+			continue
+		}
+		c.structs[declaration.Name] = &types.Struct{Name: declaration.Name}
+		declarations[declaration.Name] = declaration
+		order = append(order, declaration)
+	}
+	// 所有结构体都声明之后才解析字段的类型, 字段的类型可以是在后面声明的结构体
+	for _, declaration := range order {
+		typ := c.structs[declaration.Name]
+		fields := make(map[string]*ast.IdentifierExpression, len(declaration.Fields))
+		for _, field := range declaration.Fields {
+			if first, ok := fields[field.Value]; ok {
+				c.report(typeError(field, diagnostics.Redeclared, "duplicate field %s in struct %s", field.Value, declaration.Name).
+					WithNote(first.GetSpan(), "first declared here"))
+				continue
+			}
+			fields[field.Value] = field
+			fieldType, err := c.resolveOptionalType(field.Type)
+			if err != nil {
+				// 字段的类型标注有错误时, 字段的类型由使用推断
+				c.report(err)
+				fieldType = c.newVar(0)
+			}
+			typ.Fields = append(typ.Fields, &types.Field{Name: field.Value, Type: fieldType})
+		}
+	}
+}
+
+// inferStructLiteral 检查结构体字面值中的字段, 不能有未知的字段, 也不能缺少字段
+func (c *Checker) inferStructLiteral(node *ast.StructLiteral) (types.Type, error) {
+	typ, ok := c.structs[node.Name]
+	if !ok {
+		return nil, typeError(node, diagnostics.Undefined, "undefined struct: %s", node.Name)
+	}
+	assigned := make(map[string]struct{}, len(node.Fields))
+	for _, field := range node.Fields {
+		declared, ok := typ.Field(field.Name)
+		if !ok {
+			return nil, fieldError(field, diagnostics.Undefined, "unknown field %s in struct %s", field.Name, node.Name)
+		}
+		if _, ok := assigned[field.Name]; ok {
+			return nil, fieldError(field, diagnostics.Redeclared, "duplicate field %s in struct literal", field.Name)
+		}
+		assigned[field.Name] = struct{}{}
+		value, err := c.infer(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		if !types.Unify(declared.Type, value) {
+			return nil, mismatch(field.Value, declared.Type, value)
+		}
+	}
+	var missing []string
+	for _, field := range typ.Fields {
+		if _, ok := assigned[field.Name]; !ok {
+			missing = append(missing, field.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, typeError(node, diagnostics.TypeMismatch, "missing fields %s in struct literal of %s", strings.Join(missing, ", "), node.Name)
+	}
+	return typ, nil
+}
+
+// inferField 返回字段的类型. 不知道结构体的类型时, 如果只有一个结构体声明了这个字段, 就认为是这个结构体
+func (c *Checker) inferField(node *ast.FieldAccess) (types.Type, error) {
+	left, err := c.infer(node.Left)
+	if err != nil {
+		return nil, err
+	}
+	switch typ := types.Resolve(left).(type) {
+	case *types.Struct:
+		field, ok := typ.Field(node.Field)
+		if !ok {
+			return nil, typeError(node, diagnostics.Undefined, "unknown field %s in struct %s", node.Field, typ.Name)
+		}
+		return field.Type, nil
+	case *types.Var:
+		var candidates []*types.Struct
+		for _, declaration := range c.structs {
+			if _, ok := declaration.Field(node.Field); ok {
+				candidates = append(candidates, declaration)
+			}
+		}
+		switch len(candidates) {
+		case 0:
+			return nil, typeError(node, diagnostics.Undefined, "unknown field %s", node.Field)
+		case 1:
+			if !types.Unify(typ, candidates[0]) {
+				return nil, mismatch(node.Left, candidates[0], typ)
+			}
+			field, _ := candidates[0].Field(node.Field)
+			return field.Type, nil
+		}
+		return nil, typeError(node, diagnostics.InvalidOperation, "cannot infer which struct has field %s", node.Field)
+	}
+	return nil, typeError(node, diagnostics.InvalidOperation, "cannot access field %s of %s", node.Field, describe(left))
+}
+
+// fieldError 返回位于结构体字面值字段处的错误
+func fieldError(field *ast.StructField, code diagnostics.Code, format string, args ...interface{}) *diagnostics.Diagnostic {
+	return diagnostics.Errorf(field.Span, code, format, args...)
+}
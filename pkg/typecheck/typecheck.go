@@ -1,148 +1,496 @@
 package typecheck
 
 import (
-	"fmt"
-
 	"github.com/bootun/mini-tun/pkg/ast"
+	"github.com/bootun/mini-tun/pkg/diagnostics"
+	"github.com/bootun/mini-tun/pkg/token"
+	"github.com/bootun/mini-tun/pkg/types"
 )
 
+// Checker 推断程序中每个表达式的类型, 并检查类型是否匹配.
+// 类型推断基于统一(unification), 未知的类型用类型变量表示, 在使用时被确定.
+// 函数检查完成后, 只属于这个函数的类型变量会被泛化为类型参数, 因此同一个函数可以用于不同的类型
 type Checker struct {
-	envs    map[string]interface{}
+	env     *scope                   // 当前作用域
+	globals *scope                   // 全局作用域
+	structs map[string]*types.Struct // 程序中声明的结构体
 	program ast.Program
-}
+	nextVar int // 上一个类型变量的编号
+	level   int // 当前的泛化层级, 每进入一个函数加一
+
+	typeParams map[string]types.Type                          // 当前可见的类型参数
+	generics   map[*ast.FunctionLiteral]map[string]types.Type // 函数中可见的类型参数, 包括外层函数的类型参数
+
+	result types.Type // 正在检查的函数的返回类型, 在最外层时为nil
+	inLoop bool       // 当前语句是否位于循环体内, 用于检查break和continue
 
-type blockEnv struct {
-	envs map[string]interface{}
+	hoisted map[*ast.FunctionDeclaration]*types.Function // 提升的具名函数的类型
+
+	errors diagnostics.List // 检查过程中发现的错误, 出错的语句不影响其他语句的检查
 }
 
 func NewChecker(program ast.Program) *Checker {
-	return &Checker{
-		envs:    make(map[string]interface{}),
+	c := &Checker{
+		structs: make(map[string]*types.Struct),
 		program: program,
+		hoisted: make(map[*ast.FunctionDeclaration]*types.Function),
+
+		generics: make(map[*ast.FunctionLiteral]map[string]types.Type),
 	}
+	c.globals = newScope(c.newBuiltinScope())
+	c.env = c.globals
+	return c
 }
 
+// Check 检查整个程序, 返回的错误包含程序中所有的类型错误
 func (c *Checker) Check() error {
-	for _, stmt := range c.program.Statements {
-		refs, err := getStatementIdentifierReference(stmt)
-		if err == nil {
-			for _, ref := range refs.Refs {
-				if _, ok := c.envs[ref]; !ok {
-					return fmt.Errorf("undefined variable: %s", ref)
-				}
-			}
-		} else {
-			return fmt.Errorf("get statement identifier reference error: %v", err)
+	// 结构体只能在最外层声明, 和具名函数一样可以在声明之前使用
+	c.declareStructs(c.program.Statements)
+	c.checkStatements(c.program.Statements)
+	// 结构体和提升的具名函数在其他语句之前检查, 排序后错误按在源码中的位置排列
+	c.errors.Sort()
+	return c.errors.Err()
+}
+
+// TypeOf 返回全局变量的类型, 泛型函数的类型带有类型参数, 变量不存在时返回false
+func (c *Checker) TypeOf(name string) (*types.Scheme, bool) {
+	scheme, ok := c.globals.names[name]
+	return scheme, ok
+}
+
+// report 记录检查语句时发现的错误, 然后继续检查之后的语句
+func (c *Checker) report(err error) {
+	if err != nil {
+		c.errors = append(c.errors, diagnostics.FromError(err)...)
+	}
+}
+
+// typeError 返回一个位于节点处的类型错误
+func typeError(node ast.Node, code diagnostics.Code, format string, args ...interface{}) *diagnostics.Diagnostic {
+	return diagnostics.Errorf(node.GetSpan(), code, format, args...)
+}
+
+// mismatch 返回类型不匹配的错误
+func mismatch(node ast.Node, expected, actual types.Type) error {
+	return typeError(node, diagnostics.TypeMismatch, "type mismatch: expected %s, but got %s", describe(expected), describe(actual))
+}
+
+// describe 返回错误信息中使用的类型名, 带有约束的类型变量显示为它可以代表的类型种类,
+// 类型中其他未确定的类型变量显示为 T、U 等名字, 不显示内部的编号
+func describe(typ types.Type) string {
+	if v, ok := types.Resolve(typ).(*types.Var); ok && v.Constraint != 0 {
+		return v.Constraint.String()
+	}
+	return types.Describe(typ)
+}
+
+func (c *Checker) newVar(constraint types.Kind) *types.Var {
+	c.nextVar++
+	return types.NewVar(c.nextVar, c.level, constraint)
+}
+
+// instantiate 为类型参数创建新的类型变量, 新的类型变量保留类型参数的约束
+func (c *Checker) instantiate(scheme *types.Scheme) types.Type {
+	return scheme.Instantiate(func(v *types.Var) *types.Var {
+		return c.newVar(v.Constraint)
+	})
+}
+
+// generalize 把类型中在当前层级之后创建的类型变量泛化为类型参数.
+// 还未检查的具名函数的类型会在之后被确定, 其中的类型变量不能被泛化
+func (c *Checker) generalize(typ types.Type) *types.Scheme {
+	pending := make([]types.Type, 0, len(c.hoisted))
+	for _, typ := range c.hoisted {
+		pending = append(pending, typ)
+	}
+	return types.Generalize(typ, c.level, pending...)
+}
+
+// checkStatements 依次检查语句块中的语句, 具名函数在语句块开始时提升声明.
+// 每条语句中的错误都会被记录, 一条语句出错后仍然检查之后的语句.
+// 返回语句块是否在每条执行路径上都以return结束
+func (c *Checker) checkStatements(statements []ast.Statement) bool {
+	for _, declaration := range hoistedFunctions(statements) {
+		c.level++
+		typ, err := c.functionType(declaration.Function)
+		c.level--
+		if err != nil {
+			// 函数的类型标注有错误时不再检查函数, 函数名仍然可以使用
+			c.report(err)
+			c.env.declare(declaration.Name, c.newVar(0))
+			continue
 		}
-		c.envs[refs.VariableName] = struct{}{}
+		c.hoisted[declaration] = typ
+		c.env.declare(declaration.Name, typ)
 	}
-	return nil
+	returns := false
+	for _, stmt := range statements {
+		stmtReturns, err := c.checkStatement(stmt)
+		c.report(err)
+		returns = returns || stmtReturns
+	}
+	return returns
+}
+
+// checkBlock 在新的作用域中检查语句块, 语句块中声明的变量在语句块外不可见
+func (c *Checker) checkBlock(block *ast.BlockStatement) bool {
+	env := c.env
+	c.env = newScope(env)
+	defer func() { c.env = env }()
+	return c.checkStatements(block.Statements)
+}
+
+// checkLoopBody 检查循环体, 循环体中可以使用break和continue
+func (c *Checker) checkLoopBody(body *ast.BlockStatement) {
+	inLoop := c.inLoop
+	c.inLoop = true
+	defer func() { c.inLoop = inLoop }()
+	c.checkBlock(body)
+}
+
+// checkStatement 检查一条语句, 返回语句是否在每条执行路径上都以return结束, 以及语句自身的错误.
+// 嵌套的语句块中的错误已经被记录
+func (c *Checker) checkStatement(stmt ast.Statement) (bool, error) {
+	switch node := stmt.(type) {
+	case *ast.ReturnStatement:
+		// 返回值有错误时也认为语句以return结束, 避免再报告缺少return
+		return true, c.checkReturn(node)
+	case *ast.IfStatement:
+		c.report(c.checkCondition(node.Condition))
+		returns := c.checkBlock(node.Consequence)
+		// 没有else分支时条件不成立的路径不经过return
+		if node.Alternative == nil {
+			return false, nil
+		}
+		alternativeReturns, err := c.checkStatement(node.Alternative)
+		return returns && alternativeReturns, err
+	case *ast.BlockStatement:
+		return c.checkBlock(node), nil
+	case *ast.WhileStatement:
+		return endless(node.Condition, node.Body), c.checkSimpleStatement(stmt)
+	case *ast.ForStatement:
+		return endless(node.Condition, node.Body), c.checkSimpleStatement(stmt)
+	default:
+		return false, c.checkSimpleStatement(stmt)
+	}
+}
+
+// endless 判断循环是否只能通过return离开. 其他循环的循环体可能一次也不执行, 不保证以return结束
+func endless(condition ast.Expression, body *ast.BlockStatement) bool {
+	if condition != nil {
+		literal, ok := condition.(*ast.BooleanLiteral)
+		if !ok || !literal.Value {
+			return false
+		}
+	}
+	return !breaks(body.Statements)
 }
 
-type RefInfo struct {
-	VariableName string
-	Refs         []string
+// breaks 判断语句中是否有跳出当前循环的break, 内层循环中的break只跳出内层循环
+func breaks(statements []ast.Statement) bool {
+	for _, stmt := range statements {
+		switch node := stmt.(type) {
+		case *ast.BreakStatement:
+			return true
+		case *ast.BlockStatement:
+			if breaks(node.Statements) {
+				return true
+			}
+		case *ast.IfStatement:
+			if breaks(node.Consequence.Statements) {
+				return true
+			}
+			if node.Alternative != nil && breaks([]ast.Statement{node.Alternative}) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func getStatementIdentifierReference(stmt ast.Statement) (*RefInfo, error) {
-	switch stmt.(type) {
+// checkSimpleStatement 检查return、if和语句块以外的语句
+func (c *Checker) checkSimpleStatement(stmt ast.Statement) error {
+	switch node := stmt.(type) {
 	case *ast.VariableAssignment:
-		node := stmt.(*ast.VariableAssignment)
-		refs, err := getExpressionIdentifierReference(node.Value)
+		return c.checkVariable(node)
+	case *ast.FunctionDeclaration:
+		typ, ok := c.hoisted[node]
+		if !ok {
+			// 提升时已经报告了函数的错误
+			return nil
+		}
+		delete(c.hoisted, node)
+		scheme, err := c.checkPolymorphic(node.Function, typ, nil)
 		if err != nil {
-			return nil, fmt.Errorf("get expression identifier reference error: %v", err)
+			return err
 		}
-		return &RefInfo{
-			VariableName: node.VariableName,
-			Refs:         refs,
-		}, nil
-	case *ast.ReturnStatement:
-		node := stmt.(*ast.ReturnStatement)
-		refs, err := getExpressionIdentifierReference(node.ReturnValue)
+		c.env.declareScheme(node.Name, scheme)
+		return nil
+	case *ast.StructDecl:
+		// 结构体已经在检查开始前声明
+		return nil
+	case *ast.Assignment:
+		scheme, ok := c.env.lookup(node.VariableName)
+		if !ok {
+			return typeError(node, diagnostics.Undefined, "cannot assign to undeclared variable: %s", node.VariableName)
+		}
+		if len(scheme.Vars) > 0 {
+			return typeError(node, diagnostics.InvalidStatement, "cannot assign to generic function %s", node.VariableName)
+		}
+		return c.checkAssignment(node, node.Operator, scheme.Type, node.Value)
+	case *ast.IndexAssignment:
+		typ, err := c.inferIndex(node.Target)
+		if err != nil {
+			return err
+		}
+		return c.checkAssignment(node, node.Operator, typ, node.Value)
+	case *ast.FieldAssignment:
+		typ, err := c.inferField(node.Target)
 		if err != nil {
-			return nil, fmt.Errorf("get expression identifier reference from return statement error: %v", err)
-		}
-		return &RefInfo{
-			VariableName: "",
-			Refs:         refs,
-		}, nil
-	// case *ast.BlockStatement:
-	// 	node := stmt.(*ast.BlockStatement)
+			return err
+		}
+		return c.checkAssignment(node, node.Operator, typ, node.Value)
+	case *ast.ExpressionStatement:
+		_, err := c.infer(node.Expression)
+		return err
+	case *ast.WhileStatement:
+		c.report(c.checkCondition(node.Condition))
+		c.checkLoopBody(node.Body)
+		return nil
+	case *ast.ForStatement:
+		// for循环的初始化语句声明的变量只在循环内可见
+		env := c.env
+		c.env = newScope(env)
+		defer func() { c.env = env }()
+		if node.Init != nil {
+			c.report(c.checkSimpleStatement(node.Init))
+		}
+		if node.Condition != nil {
+			c.report(c.checkCondition(node.Condition))
+		}
+		c.checkLoopBody(node.Body)
+		if node.Post != nil {
+			return c.checkSimpleStatement(node.Post)
+		}
+		return nil
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		if !c.inLoop {
+			return typeError(node, diagnostics.InvalidStatement, "%s is not in a loop", node.TokenLiteral())
+		}
+		return nil
 	default:
-		return nil, fmt.Errorf("unsupported statement type: %T", stmt)
+		return typeError(stmt, diagnostics.InvalidStatement, "unsupported statement type: %T", stmt)
+	}
+}
+
+// checkReturn 检查return语句, 返回值的类型必须和函数的返回类型相同
+func (c *Checker) checkReturn(node *ast.ReturnStatement) error {
+	typ, err := c.infer(node.ReturnValue)
+	if err != nil {
+		return err
+	}
+	// 最外层的return只会结束程序, 不限制返回值的类型
+	if c.result == nil {
+		return nil
 	}
+	if !types.Unify(c.result, typ) {
+		return mismatch(node.ReturnValue, c.result, typ)
+	}
+	return nil
 }
 
-func getExpressionIdentifierReference(expr ast.Expression) ([]string, error) {
-	switch expr.(type) {
-	case *ast.IdentifierExpression:
-		node := expr.(*ast.IdentifierExpression)
-		return []string{node.Value}, nil
-	case *ast.ComplexExpression:
-		var refs []string
-		node := expr.(*ast.ComplexExpression)
-		leftRefs, err := getExpressionIdentifierReference(node.Left)
+// checkVariable 检查变量声明, 有类型标注时值的类型必须和标注的类型相同
+func (c *Checker) checkVariable(node *ast.VariableAssignment) error {
+	var declared types.Type
+	if node.Type != nil {
+		typ, err := c.resolveType(node.Type)
+		if err != nil {
+			return err
+		}
+		declared = typ
+	}
+	// 函数可以在函数体中引用自身, 以支持递归
+	if function, ok := node.Value.(*ast.FunctionLiteral); ok {
+		scheme, err := c.checkPolymorphic(function, nil, func(typ *types.Function) error {
+			c.env.declare(node.VariableName, typ)
+			// 没有类型参数的函数先和类型标注统一, 以便用标注的类型检查函数体
+			if declared != nil && len(function.TypeParameters) == 0 && !types.Unify(declared, typ) {
+				return mismatch(node.Value, declared, typ)
+			}
+			return nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("get expression identifier reference error: %v", err)
+			if _, ok := c.env.names[node.VariableName]; !ok {
+				c.env.declare(node.VariableName, c.newVar(0))
+			}
+			return err
+		}
+		if declared == nil {
+			c.env.declareScheme(node.VariableName, scheme)
+			return nil
+		}
+		// 类型标注不能表示泛型函数, 变量的类型是泛型函数的一个实例
+		if typ := c.instantiate(scheme); !types.Unify(declared, typ) {
+			return mismatch(node.Value, declared, typ)
 		}
-		rightRefs, err := getExpressionIdentifierReference(node.Right)
+		c.env.declare(node.VariableName, declared)
+		return nil
+	}
+	typ, err := c.infer(node.Value)
+	if err != nil {
+		// 值有错误时仍然声明变量, 避免之后使用变量时报告更多的错误
+		if declared == nil {
+			declared = c.newVar(0)
+		}
+		c.env.declare(node.VariableName, declared)
+		return err
+	}
+	if declared != nil && !types.Unify(declared, typ) {
+		c.env.declare(node.VariableName, declared)
+		return mismatch(node.Value, declared, typ)
+	}
+	c.env.declare(node.VariableName, typ)
+	return nil
+}
+
+// compoundOperators 复合赋值运算符对应的二元运算符
+var compoundOperators = map[token.TokenType]token.TokenType{
+	token.PLUS_ASSIGN:     token.PLUS,
+	token.MINUS_ASSIGN:    token.MINUS,
+	token.ASTERISK_ASSIGN: token.ASTERISK,
+	token.SLASH_ASSIGN:    token.SLASH,
+}
+
+// checkAssignment 检查赋值语句, 赋的值必须和被赋值的变量、元素或字段的类型相同
+func (c *Checker) checkAssignment(node ast.Node, operator token.Token, target types.Type, value ast.Expression) error {
+	typ, err := c.infer(value)
+	if err != nil {
+		return err
+	}
+	if binary, ok := compoundOperators[operator.Type]; ok {
+		typ, err = c.binary(node, binary, target, typ)
 		if err != nil {
-			return nil, fmt.Errorf("get expression identifier reference error: %v", err)
-		}
-		refs = append(refs, leftRefs...)
-		refs = append(refs, rightRefs...)
-		return refs, nil
-	case *ast.LiteralExpression:
-		return []string{}, nil
-	case *ast.FunctionLiteral:
-		node := expr.(*ast.FunctionLiteral)
-		externalRefs, err := parseBlockIdentifierReference(node.Body)
+			return err
+		}
+	}
+	if !types.Unify(target, typ) {
+		return mismatch(value, target, typ)
+	}
+	return nil
+}
+
+// checkCondition 检查if、while和for中的条件, 条件的类型必须是bool
+func (c *Checker) checkCondition(condition ast.Expression) error {
+	typ, err := c.infer(condition)
+	if err != nil {
+		return err
+	}
+	if !types.Unify(types.Bool, typ) {
+		return mismatch(condition, types.Bool, typ)
+	}
+	return nil
+}
+
+// functionType 为函数字面值创建函数类型, 使用参数和返回值的类型标注,
+// 没有标注的类型在检查函数体和调用时推断
+func (c *Checker) functionType(function *ast.FunctionLiteral) (*types.Function, error) {
+	typeParams, err := c.declareTypeParams(function)
+	if err != nil {
+		return nil, err
+	}
+	outer := c.typeParams
+	c.typeParams = typeParams
+	defer func() { c.typeParams = outer }()
+	params := make([]types.Type, 0, len(function.Parameters))
+	for _, param := range function.Parameters {
+		typ, err := c.resolveOptionalType(param.Type)
 		if err != nil {
-			return nil, fmt.Errorf("parse function body error: %v", err)
+			return nil, err
 		}
-		parameters := make(map[string]struct{})
-		for _, param := range node.Parameters {
-			parameters[param.Value] = struct{}{}
+		params = append(params, typ)
+	}
+	result, err := c.resolveOptionalType(function.ReturnType)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Function{Params: params, Result: result}, nil
+}
+
+// declareTypeParams 为函数的类型参数创建类型变量, 返回函数中可见的所有类型参数
+func (c *Checker) declareTypeParams(function *ast.FunctionLiteral) (map[string]types.Type, error) {
+	typeParams := make(map[string]types.Type, len(c.typeParams)+len(function.TypeParameters))
+	for name, typ := range c.typeParams {
+		typeParams[name] = typ
+	}
+	declared := make(map[string]*ast.IdentifierExpression, len(function.TypeParameters))
+	for _, param := range function.TypeParameters {
+		if first, ok := declared[param.Value]; ok {
+			return nil, typeError(param, diagnostics.Redeclared, "duplicate type parameter %s", param.Value).
+				WithNote(first.GetSpan(), "first declared here")
 		}
-		for _, ref := range externalRefs {
-			if _, ok := parameters[ref]; !ok {
-				return nil, fmt.Errorf("undefined variable: %s", ref)
+		declared[param.Value] = param
+		c.nextVar++
+		typeParams[param.Value] = types.NewParam(c.nextVar, c.level, param.Value)
+	}
+	c.generics[function] = typeParams
+	return typeParams, nil
+}
+
+// checkPolymorphic 在更深的层级中检查函数, 然后把只属于这个函数的类型变量泛化为类型参数.
+// typ为nil时为函数创建新的类型, before不为nil时在检查函数体之前调用
+func (c *Checker) checkPolymorphic(function *ast.FunctionLiteral, typ *types.Function, before func(typ *types.Function) error) (*types.Scheme, error) {
+	err := func() error {
+		c.level++
+		defer func() { c.level-- }()
+		if typ == nil {
+			var err error
+			if typ, err = c.functionType(function); err != nil {
+				return err
 			}
 		}
-
-		return []string{}, nil
-	case *ast.FunctionCall:
-		node := expr.(*ast.FunctionCall)
-		var refs []string
-		refs = append(refs, node.FunctionName)
-		for _, arg := range node.Arguments {
-			argRefs, err := getExpressionIdentifierReference(arg)
-			if err != nil {
-				return nil, fmt.Errorf("get expression identifier reference error: %v", err)
+		if before != nil {
+			if err := before(typ); err != nil {
+				return err
 			}
-			refs = append(refs, argRefs...)
 		}
-		return refs, nil
+		// 函数体中的错误不影响函数的类型, 函数仍然可以被泛化
+		c.report(c.checkFunction(function, typ))
+		return nil
+	}()
+	if err != nil {
+		return nil, err
 	}
-	return nil, nil
+	return c.generalize(typ), nil
 }
 
-// block只会在下级作用域增加变量，不会给上级作用域增加变量
-func parseBlockIdentifierReference(block *ast.BlockStatement) ([]string, error) {
-	envs := make(map[string]interface{})
-	var externalRefs []string
-	for _, stmt := range block.Statements {
-		refs, err := getStatementIdentifierReference(stmt)
-		if err == nil {
-			for _, ref := range refs.Refs {
-				if _, ok := envs[ref]; !ok {
-					externalRefs = append(externalRefs, ref)
-				}
-			}
-		} else {
-			return []string{}, fmt.Errorf("get statement identifier reference error: %v", err)
+// checkFunction 在新的作用域中检查函数体, 参数绑定在函数自己的作用域中
+func (c *Checker) checkFunction(function *ast.FunctionLiteral, typ *types.Function) error {
+	env, result, inLoop, typeParams := c.env, c.result, c.inLoop, c.typeParams
+	defer func() {
+		c.env, c.result, c.inLoop, c.typeParams = env, result, inLoop, typeParams
+	}()
+	c.env, c.result, c.inLoop, c.typeParams = newScope(env), typ.Result, false, c.generics[function]
+	for i, param := range function.Parameters {
+		c.env.declare(param.Value, typ.Params[i])
+	}
+	returns := false
+	if function.Body != nil {
+		returns = c.checkStatements(function.Body.Statements)
+	}
+	// 函数体可能不经过return结束时, 函数不能有返回值
+	if !returns && !types.Unify(typ.Result, types.Void) {
+		return typeError(function, diagnostics.MissingReturn, "missing return in function returning %s", describe(typ.Result))
+	}
+	return nil
+}
+
+// hoistedFunctions 返回语句列表中的具名函数声明, 这些函数在整个语句块中都可见
+func hoistedFunctions(statements []ast.Statement) []*ast.FunctionDeclaration {
+	var declarations []*ast.FunctionDeclaration
+	for _, stmt := range statements {
+		if declaration, ok := stmt.(*ast.FunctionDeclaration); ok {
+			declarations = append(declarations, declaration)
 		}
-		envs[refs.VariableName] = struct{}{}
 	}
-	return externalRefs, nil
+	return declarations
 }
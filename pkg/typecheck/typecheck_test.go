@@ -0,0 +1,926 @@
+package typecheck
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bootun/mini-tun/pkg/diagnostics"
+	"github.com/bootun/mini-tun/pkg/lexer"
+	"github.com/bootun/mini-tun/pkg/parser"
+)
+
+func TestChecker_Check(t *testing.T) {
+	type fields struct {
+		input string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		wantErr string // 期望错误信息中包含的内容, 为空表示期望检查通过
+	}{
+		{
+			name: "defined_variables",
+			fields: fields{
+				`
+let a = 1
+let add = function(a, b) {
+	return a + b
+}
+let c = add(a, 2)`,
+			},
+		},
+		{
+			name: "undefined_variable",
+			fields: fields{
+				`
+let a = 1
+let c = a + b`,
+			},
+			wantErr: "3:13: undefined variable: b",
+		},
+		{
+			name: "closure_references_outer_scope",
+			fields: fields{
+				`
+let base = 10
+let makeAdder = function(x) {
+	return function(y) {
+		return base + x + y
+	}
+}`,
+			},
+		},
+		{
+			name: "closure_references_undefined_variable",
+			fields: fields{
+				`
+let f = function(x) {
+	return function(y) {
+		return x + z
+	}
+}`,
+			},
+			wantErr: "undefined variable: z",
+		},
+		{
+			name: "recursive_function",
+			fields: fields{
+				`
+let fib = function(n) {
+	if n < 2 {
+		return n
+	}
+	return fib(n - 1) + fib(n - 2)
+}`,
+			},
+		},
+		{
+			name: "hoisted_function_declarations",
+			fields: fields{
+				`
+let a = isEven(4)
+function isEven(n) {
+	if n == 0 {
+		return true
+	}
+	return isOdd(n - 1)
+}
+function isOdd(n) {
+	if n == 0 {
+		return false
+	}
+	return isEven(n - 1)
+}`,
+			},
+		},
+		{
+			name: "non_function_cannot_reference_itself",
+			fields: fields{
+				`let a = a + 1`,
+			},
+			wantErr: "undefined variable: a",
+		},
+		{
+			name: "string_operations",
+			fields: fields{
+				`
+let a = "x" + 1
+let b = "${a}!" == "x1!"
+let c = "a" < "b"`,
+			},
+		},
+		{
+			name: "invalid_string_operation",
+			fields: fields{
+				`let a = "a" - 1`,
+			},
+			wantErr: "1:9: cannot subtract int from string",
+		},
+		{
+			name: "numeric_operations",
+			fields: fields{
+				`
+let a = 1 + 2.5
+let b = int(a / 2) % 3
+let c = float(b) < a`,
+			},
+		},
+		{
+			name: "float_remainder",
+			fields: fields{
+				`let a = 7 / 2 % 2`,
+			},
+			wantErr: "1:9: cannot take remainder of float divided by int",
+		},
+		{
+			name: "array_element_type",
+			fields: fields{
+				`let a = [1, 2][0] - "a"`,
+			},
+			wantErr: "1:9: cannot subtract string from int",
+		},
+		{
+			name: "array_index_type",
+			fields: fields{
+				`
+let a = [1, 2]
+let b = a["0"]`,
+			},
+			wantErr: "3:11: invalid array index type string",
+		},
+		{
+			name: "inferred_array_index_type",
+			fields: fields{
+				`
+function f(a) {
+	let first = a[0]
+	return a["0"]
+}`,
+			},
+			wantErr: "4:11: invalid array index type string",
+		},
+		{
+			name: "array_literal_index_type",
+			fields: fields{
+				`let b = [1, 2]["0"]`,
+			},
+			wantErr: "1:16: invalid array index type string",
+		},
+		{
+			name: "index_non_array",
+			fields: fields{
+				`let a = 1[0]`,
+			},
+			wantErr: "1:9: cannot index int",
+		},
+		{
+			name: "map_value_type",
+			fields: fields{
+				`
+let m = {"a": 1}
+m["b"] = m["a"]
+let ok = has(m, "b")
+let b = {"a": "x"}["a"] - 1`,
+			},
+			wantErr: "5:9: cannot subtract int from string",
+		},
+		{
+			name: "mixed_map_keys",
+			fields: fields{
+				`
+let m = {"b": 1, 2: 20}
+m["a"] = 10
+m[3] += 5
+let ok = has(m, "a") && has(m, 4)
+let k = keys(m)[0] + "!"`,
+			},
+		},
+		{
+			name: "mixed_map_key_is_not_ordered",
+			fields: fields{
+				`
+let m = {"b": 1, 2: 20}
+let k = keys(m)[0] - 1`,
+			},
+			wantErr: "3:9: cannot subtract int from int | string",
+		},
+		{
+			name: "invalid_mixed_map_key",
+			fields: fields{
+				`
+let m = {"b": 1, 2: 20}
+let v = m[1.5]`,
+			},
+			wantErr: "3:11: invalid map key type float",
+		},
+		{
+			name: "duplicate_map_key",
+			fields: fields{
+				`let m = {"a": 1, "a": 2}`,
+			},
+			wantErr: `1:18: duplicate key "a" in map literal`,
+		},
+		{
+			name: "invalid_map_key_type",
+			fields: fields{
+				`let m = {1.5: 1}`,
+			},
+			wantErr: "1:10: invalid map key type float",
+		},
+		{
+			name: "struct_fields",
+			fields: fields{
+				`
+let p = Point{x: 1, y: 2}
+p.x = p.y
+struct Point { x, y }`,
+			},
+		},
+		{
+			name: "struct_unknown_field",
+			fields: fields{
+				`
+struct Point { x, y }
+let p = Point{x: 1, y: 2, z: 3}`,
+			},
+			wantErr: "3:27: unknown field z in struct Point",
+		},
+		{
+			name: "struct_missing_field",
+			fields: fields{
+				`
+struct Point { x, y }
+let p = Point{y: 2}`,
+			},
+			wantErr: "3:9: missing fields x in struct literal of Point",
+		},
+		{
+			name: "unknown_field_access",
+			fields: fields{
+				`
+struct Point { x, y }
+let p = Point{x: 1, y: 2}
+let z = p.z + Point{x: 1, y: 2}.y`,
+			},
+			wantErr: "4:9: unknown field z",
+		},
+		{
+			name: "undefined_struct",
+			fields: fields{
+				`let p = Point{x: 1}`,
+			},
+			wantErr: "1:9: undefined struct: Point",
+		},
+		{
+			name: "undefined_callee",
+			fields: fields{
+				`let a = [1][0](makeAdder(1)(2))`,
+			},
+			wantErr: "1:16: undefined variable: makeAdder",
+		},
+		{
+			name: "not_enough_arguments",
+			fields: fields{
+				`
+let add = function(a, b) {
+	return a + b
+}
+let e = add(10, add(1))`,
+			},
+			wantErr: "5:17: not enough arguments in call to add: expected 2, but got 1",
+		},
+		{
+			name: "too_many_arguments_to_builtin",
+			fields: fields{
+				`let n = len([1], 2)`,
+			},
+			wantErr: "1:9: too many arguments in call to len: expected 1, but got 2",
+		},
+		{
+			name: "immediately_invoked_function_arity",
+			fields: fields{
+				`let a = (function(x) { return x })(1, 2)`,
+			},
+			wantErr: "1:9: too many arguments in call to function literal: expected 1, but got 2",
+		},
+		{
+			name: "arity_checked_through_closures_and_recursion",
+			fields: fields{
+				`
+function fact(n) {
+	if n == 0 {
+		return 1
+	}
+	return n * fact(n - 1, 1)
+}`,
+			},
+			wantErr: "6:13: too many arguments in call to fact: expected 1, but got 2",
+		},
+		{
+			name: "arity_inferred_from_call",
+			fields: fields{
+				`
+let call = function(f) {
+	return f(1, 2)
+}
+let r = call(function(a, b) {
+	return a + b
+})`,
+			},
+		},
+		{
+			name: "cannot_add_function_and_int",
+			fields: fields{
+				`
+let f = function(a) { return a }
+let x = f + 1`,
+			},
+			wantErr: "3:9: cannot add function(",
+		},
+		{
+			name: "argument_type_mismatch",
+			fields: fields{
+				`
+let add = function(a, b) { return a + b }
+let x = add(1, "a")`,
+			},
+			wantErr: "3:16: type mismatch: expected int, but got string",
+		},
+		{
+			name: "builtin_argument_type_mismatch",
+			fields: fields{
+				`let n = len(1)`,
+			},
+			wantErr: "1:13: type mismatch: expected string | array | map, but got int",
+		},
+		{
+			name: "return_type_mismatch",
+			fields: fields{
+				`
+let f = function(x) {
+	if x {
+		return 1
+	}
+	return "a"
+}`,
+			},
+			wantErr: "6:9: type mismatch: expected int, but got string",
+		},
+		{
+			name: "missing_return",
+			fields: fields{
+				`
+let x = f() + 1
+function f() {
+	let a = 1
+}`,
+			},
+			wantErr: "3:1: missing return in function returning int",
+		},
+		{
+			name: "missing_return_without_else",
+			fields: fields{
+				`
+let f = function(x: bool): int {
+	if x {
+		return 1
+	}
+}`,
+			},
+			wantErr: "2:9: missing return in function returning int",
+		},
+		{
+			name: "missing_return_after_loop",
+			fields: fields{
+				`
+function f(n) {
+	while n > 0 {
+		return n
+	}
+}`,
+			},
+			wantErr: "2:1: missing return in function returning int",
+		},
+		{
+			name: "endless_loop_returns",
+			fields: fields{
+				`
+function f(): int {
+	while true {
+		return 1
+	}
+}
+function g(n) {
+	while true {
+		for let i = 0; i < n; i += 1 {
+			break
+		}
+		return n
+	}
+}`,
+			},
+		},
+		{
+			name: "endless_loop_with_break",
+			fields: fields{
+				`
+function f(n): int {
+	while true {
+		if n > 0 {
+			break
+		}
+		return 1
+	}
+}`,
+			},
+			wantErr: "2:1: missing return in function returning int",
+		},
+		{
+			name: "return_in_every_branch",
+			fields: fields{
+				`
+function sign(n) {
+	if n > 0 {
+		return 1
+	} else if n < 0 {
+		return -1
+	} else {
+		return 0
+	}
+}
+let s = sign(3)`,
+			},
+		},
+		{
+			name: "condition_must_be_bool",
+			fields: fields{
+				`
+let n = 1
+while n {
+}`,
+			},
+			wantErr: "3:7: type mismatch: expected bool, but got int",
+		},
+		{
+			name: "variable_type_is_fixed",
+			fields: fields{
+				`
+let a = 1
+a = "x"`,
+			},
+			wantErr: "3:5: type mismatch: expected int, but got string",
+		},
+		{
+			name: "division_result_is_float",
+			fields: fields{
+				`
+let a = 1
+a /= 2`,
+			},
+			wantErr: "3:6: type mismatch: expected int, but got float",
+		},
+		{
+			name: "array_elements_have_same_type",
+			fields: fields{
+				`let a = [1, "a"]`,
+			},
+			wantErr: "1:13: type mismatch: expected int, but got string",
+		},
+		{
+			name: "struct_field_type",
+			fields: fields{
+				`
+struct Point { x, y }
+let a = Point{x: 1, y: 2}
+let b = Point{x: "1", y: 2}`,
+			},
+			wantErr: "4:18: type mismatch: expected int, but got string",
+		},
+		{
+			name: "value_is_not_callable",
+			fields: fields{
+				`
+let a = 1
+let b = a()`,
+			},
+			wantErr: "3:9: value is not callable: a is int",
+		},
+		{
+			name: "call_with_itself",
+			fields: fields{
+				`let f = function(x) { return x(x) }`,
+			},
+			wantErr: "1:30: cannot infer type of x: its type would have to contain itself",
+		},
+		{
+			name: "type_variables_are_named",
+			fields: fields{
+				`
+let f = function(x) { return x }
+let y = f + 1`,
+			},
+			wantErr: "3:9: cannot add function(T) T and int",
+		},
+		{
+			name: "prefix_on_generic_function",
+			fields: fields{
+				`
+let add = function(a, b) { return a + b }
+let n = -add`,
+			},
+			wantErr: "3:9: cannot apply - to function(T, T) T",
+		},
+		{
+			name: "map_keys_type",
+			fields: fields{
+				`
+let m = {"a": 1}
+let k = keys(m)[0] - 1`,
+			},
+			wantErr: "3:9: cannot subtract int from string",
+		},
+		{
+			name: "field_access_on_parameter",
+			fields: fields{
+				`
+struct Point { x, y }
+function norm(p) {
+	return p.x * p.x + p.y * p.y
+}
+let n = norm(Point{x: 3, y: 4}) + 1`,
+			},
+		},
+		{
+			name: "variable_annotation_mismatch",
+			fields: fields{
+				`let x: int = "a"`,
+			},
+			wantErr: "1:14: type mismatch: expected int, but got string",
+		},
+		{
+			name: "parameter_annotation_mismatch",
+			fields: fields{
+				`
+let half = function(a: float): float {
+	return a / 2
+}
+let h = half("1")`,
+			},
+			wantErr: "5:14: type mismatch: expected float, but got string",
+		},
+		{
+			name: "return_annotation_mismatch",
+			fields: fields{
+				`
+function name(p: Person): int {
+	return p.name
+}
+struct Person { name: string }`,
+			},
+			wantErr: "3:9: type mismatch: expected int, but got string",
+		},
+		{
+			name: "function_annotation_mismatch",
+			fields: fields{
+				`let f: function(int) int = function(a: int) { return a > 0 }`,
+			},
+			wantErr: "1:54: type mismatch: expected int, but got bool",
+		},
+		{
+			name: "function_parameter_annotation_mismatch",
+			fields: fields{
+				`let f: function(int) int = function(a: string) { return 1 }`,
+			},
+			wantErr: "1:28: type mismatch: expected function(int) int, but got function(string)",
+		},
+		{
+			name: "empty_array_annotation",
+			fields: fields{
+				`
+let a: []string = []
+a[0] = 1`,
+			},
+			wantErr: "3:8: type mismatch: expected string, but got int",
+		},
+		{
+			name: "undefined_type",
+			fields: fields{
+				`let a: []Point = []`,
+			},
+			wantErr: "1:10: undefined type: Point",
+		},
+		{
+			name: "invalid_map_key_annotation",
+			fields: fields{
+				`let m: map[bool]int = {}`,
+			},
+			wantErr: "1:12: invalid map key type bool",
+		},
+		{
+			name: "block_variable_not_visible_outside",
+			fields: fields{
+				`
+if true {
+	let a = 1
+}
+let b = a`,
+			},
+			wantErr: "undefined variable: a",
+		},
+		{
+			name: "assign_to_undeclared_variable",
+			fields: fields{
+				`x += 1`,
+			},
+			wantErr: "cannot assign to undeclared variable: x",
+		},
+		{
+			name: "break_outside_loop",
+			fields: fields{
+				`
+while true {
+	let f = function() {
+		break
+	}
+}`,
+			},
+			wantErr: "break is not in a loop",
+		},
+		{
+			name: "let_polymorphism",
+			fields: fields{
+				`
+let id = function(x) { return x }
+let a = id(1) + 1
+let b = id("a") + "b"`,
+			},
+		},
+		{
+			name: "generic_function",
+			fields: fields{
+				`
+function first<A, B>(a: A, b: B): A { return a }
+let c = first(1, "x") + 2
+let d = first(true, 1) && false
+let apply = function<T>(x: T, f: function(T) T): T { return f(x) }
+let s = apply("s", function(s) { return s + "!" })`,
+			},
+		},
+		{
+			name:    "type_parameter_has_no_operators",
+			fields:  fields{`function inc<T>(x: T): T { return x + 1 }`},
+			wantErr: "1:35: cannot add T and int",
+		},
+		{
+			name:    "type_parameter_is_not_concrete",
+			fields:  fields{`function one<T>(x: T): T { return 1 }`},
+			wantErr: "1:35: type mismatch: expected T, but got int",
+		},
+		{
+			name:    "duplicate_type_parameter",
+			fields:  fields{`function f<T, T>(x: T) {}`},
+			wantErr: "1:15: duplicate type parameter T",
+		},
+		{
+			name: "generic_instance_mismatch",
+			fields: fields{
+				`
+function id<T>(x: T): T { return x }
+let a: string = id(1)`,
+			},
+			wantErr: "3:17: type mismatch: expected string, but got int",
+		},
+		{
+			name: "assign_to_generic_function",
+			fields: fields{
+				`
+let id = function(x) { return x }
+id = function(y) { return y }`,
+			},
+			wantErr: "3:1: cannot assign to generic function id",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := parser.New(lexer.New(tt.fields.input)).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			err = NewChecker(program).Check()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Check() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChecker_TypeOf(t *testing.T) {
+	type fields struct {
+		input string
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		variable string
+		want     string
+	}{
+		{
+			name:     "mixed_arithmetic",
+			fields:   fields{`let a = 1 + 2.5`},
+			variable: "a",
+			want:     "float",
+		},
+		{
+			name:     "string_concatenation",
+			fields:   fields{`let s = "a" + 1`},
+			variable: "s",
+			want:     "string",
+		},
+		{
+			name: "function_inferred_from_call",
+			fields: fields{
+				`
+let add = function(a, b) { return a + b }
+let x = add(1, 2)`,
+			},
+			variable: "add",
+			want:     "function(T, T) T where T: int | float | string",
+		},
+		{
+			name: "generic_function_call",
+			fields: fields{
+				`
+let add = function(a, b) { return a + b }
+let x = add(1, 2)`,
+			},
+			variable: "x",
+			want:     "int",
+		},
+		{
+			name: "recursive_function",
+			fields: fields{
+				`
+function fact(n) {
+	if n == 0 {
+		return 1
+	}
+	return n * fact(n - 1)
+}`,
+			},
+			variable: "fact",
+			want:     "function(int) int",
+		},
+		{
+			name:     "function_without_return",
+			fields:   fields{`let f = function() { let a = 1 }`},
+			variable: "f",
+			want:     "function()",
+		},
+		{
+			name: "builtin_call",
+			fields: fields{
+				`
+let f = function(x) { return len(x) }
+let n = f("abc")`,
+			},
+			variable: "f",
+			want:     "function(T) int where T: string | array | map",
+		},
+		{
+			name:     "map_of_arrays",
+			fields:   fields{`let m = {"a": [1]}`},
+			variable: "m",
+			want:     "map[string][]int",
+		},
+		{
+			name:     "mixed_map_keys",
+			fields:   fields{`let m = {"a": 1, 2: 3}`},
+			variable: "m",
+			want:     "map[int | string]int",
+		},
+		{
+			name:     "slice",
+			fields:   fields{`let b = [1, 2][0:1]`},
+			variable: "b",
+			want:     "[]int",
+		},
+		{
+			name: "annotated_parameter",
+			fields: fields{
+				`let scale = function(a: float, n) { return a * n }`,
+			},
+			variable: "scale",
+			want:     "function(float, float) float",
+		},
+		{
+			name: "annotated_struct_fields",
+			fields: fields{
+				`
+struct Line { from: Point, to: Point }
+struct Point { x: float, y: float }
+let length = function(l) { return l.to.x - l.from.x }`,
+			},
+			variable: "length",
+			want:     "function(Line) float",
+		},
+		{
+			name: "struct_field",
+			fields: fields{
+				`
+struct Point { x, y }
+let p = Point{x: 1, y: 2.5}
+let y = p.y`,
+			},
+			variable: "y",
+			want:     "float",
+		},
+		{
+			name:     "generic_function",
+			fields:   fields{`function id<T>(x: T): T { return x }`},
+			variable: "id",
+			want:     "function(T) T",
+		},
+		{
+			name: "generic_instance",
+			fields: fields{
+				`
+function pair<A, B>(a: A, b: B): map[string]B { return {"b": b} }
+let p = pair(1, [true])`,
+			},
+			variable: "p",
+			want:     "map[string][]bool",
+		},
+		{
+			name:     "annotated_generic_function",
+			fields:   fields{`let f: function(int) int = function<T>(x: T): T { return x }`},
+			variable: "f",
+			want:     "function(int) int",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := parser.New(lexer.New(tt.fields.input)).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			checker := NewChecker(program)
+			if err := checker.Check(); err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			typ, ok := checker.TypeOf(tt.variable)
+			if !ok {
+				t.Fatalf("TypeOf(%q) not found", tt.variable)
+			}
+			if got := typ.String(); got != tt.want {
+				t.Errorf("TypeOf(%q) = %s, want %s", tt.variable, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecker_CheckReportsAllErrors(t *testing.T) {
+	input := `
+struct Point { x, x }
+let a = 1 + "s" * 2
+let b = a + 1
+let m = {"k": 1, "k": 2}
+function f(x: Foo) {}
+let g = function(): int { let q = missing }
+if 1 { let z = w }
+let c = b + f(1) + g()`
+	program, err := parser.New(lexer.New(input)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	list := diagnostics.FromError(NewChecker(program).Check())
+	type result struct {
+		message string
+		code    diagnostics.Code
+		notes   int
+	}
+	var got []result
+	for _, d := range list {
+		got = append(got, result{d.Error(), d.Code, len(d.Notes)})
+	}
+	// 出错的变量仍然被声明, 之后使用这些变量不会报告更多的错误
+	want := []result{
+		{"2:19: duplicate field x in struct Point", diagnostics.Redeclared, 1},
+		{"3:13: cannot multiply string by int", diagnostics.InvalidOperation, 0},
+		{"5:18: duplicate key \"k\" in map literal", diagnostics.Redeclared, 1},
+		{"6:15: undefined type: Foo", diagnostics.Undefined, 0},
+		{"7:9: missing return in function returning int", diagnostics.MissingReturn, 0},
+		{"7:35: undefined variable: missing", diagnostics.Undefined, 0},
+		{"8:4: type mismatch: expected bool, but got int", diagnostics.TypeMismatch, 0},
+		{"8:16: undefined variable: w", diagnostics.Undefined, 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() errors = %v, want %v", got, want)
+	}
+}
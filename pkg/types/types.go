@@ -0,0 +1,312 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type 表示一个静态类型
+type Type interface {
+	String() string
+}
+
+// Kind 表示类型的种类, 多个种类可以组成一个集合, 用来约束类型变量可以代表哪些类型
+type Kind uint
+
+const (
+	KindInt Kind = 1 << iota
+	KindFloat
+	KindBool
+	KindString
+	KindVoid
+	KindArray
+	KindMap
+	KindFunction
+	KindStruct
+	KindKey // 同时使用int和string作为键时的键类型
+)
+
+// 运算符和内置函数常用的种类集合
+const (
+	Numeric   = KindInt | KindFloat              // 可以进行算术运算
+	Ordered   = Numeric | KindString             // 可以比较大小, 也可以进行 + 运算
+	Primitive = Ordered | KindBool | KindKey     // 基本类型, 可以用 == 比较, 也可以和字符串拼接
+	MapKey    = KindInt | KindString | KindKey   // 可以作为字典的键
+	Sized     = KindString | KindArray | KindMap // 可以用len求长度
+)
+
+var kindNames = []string{"int", "float", "bool", "string", "void", "array", "map", "function", "struct", "int | string"}
+
+// String 返回集合中所有种类的名字, 如 int | float
+func (k Kind) String() string {
+	// 同时包含int和string时不再单独列出 int | string
+	if k&KindInt != 0 && k&KindString != 0 {
+		k &^= KindKey
+	}
+	var names []string
+	for i, name := range kindNames {
+		if k&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, " | ")
+}
+
+// Basic 表示基本类型
+type Basic struct {
+	name string
+	kind Kind
+}
+
+func (b *Basic) String() string {
+	return b.name
+}
+
+var (
+	Int    = &Basic{name: "int", kind: KindInt}
+	Float  = &Basic{name: "float", kind: KindFloat}
+	Bool   = &Basic{name: "bool", kind: KindBool}
+	String = &Basic{name: "string", kind: KindString}
+	Void   = &Basic{name: "void", kind: KindVoid}        // 没有返回值的函数的返回类型
+	Key    = &Basic{name: "int | string", kind: KindKey} // 同时使用int和string作为键的字典的键类型
+)
+
+// Array 表示数组类型, 如 []int
+type Array struct {
+	Elem Type
+}
+
+func (a *Array) String() string {
+	return "[]" + a.Elem.String()
+}
+
+// Map 表示字典类型, 如 map[string]int
+type Map struct {
+	Key   Type
+	Value Type
+}
+
+func (m *Map) String() string {
+	return "map[" + m.Key.String() + "]" + m.Value.String()
+}
+
+// Function 表示函数类型, 如 function(int, int) int, 没有返回值的函数的返回类型为Void
+type Function struct {
+	Params []Type
+	Result Type
+}
+
+func (f *Function) String() string {
+	params := make([]string, 0, len(f.Params))
+	for _, param := range f.Params {
+		params = append(params, param.String())
+	}
+	s := "function(" + strings.Join(params, ", ") + ")"
+	if Resolve(f.Result) != Void {
+		s += " " + f.Result.String()
+	}
+	return s
+}
+
+// Struct 表示结构体类型, 结构体类型按声明区分, 只有同一个声明的结构体类型才相同
+type Struct struct {
+	Name   string
+	Fields []*Field
+}
+
+// Field 表示结构体的字段
+type Field struct {
+	Name string
+	Type Type
+}
+
+func (s *Struct) String() string {
+	return s.Name
+}
+
+// Field 按名字查找结构体的字段
+func (s *Struct) Field(name string) (*Field, bool) {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
+// Var 表示类型推断中尚未确定的类型, 在统一时被确定为具体的类型.
+// 有名字的类型变量是源码中声明的类型参数, 如 function<T>(x: T) 中的T, 它只和自身相同
+type Var struct {
+	ID         int
+	Name       string // 类型参数的名字, 推断产生的类型变量为空
+	Level      int    // 创建类型变量时所在的层级, 泛化时只有比当前层级深的类型变量会成为类型参数
+	Constraint Kind   // 类型变量可以代表的类型种类, 为0时没有限制
+	Instance   Type   // 类型变量被确定后代表的类型, 未确定时为nil
+}
+
+func NewVar(id int, level int, constraint Kind) *Var {
+	return &Var{ID: id, Level: level, Constraint: constraint}
+}
+
+// NewParam 创建源码中声明的类型参数
+func NewParam(id int, level int, name string) *Var {
+	return &Var{ID: id, Name: name, Level: level}
+}
+
+// IsParam 判断类型变量是否是源码中声明的类型参数
+func (v *Var) IsParam() bool {
+	return v.Name != ""
+}
+
+func (v *Var) String() string {
+	if v.Instance != nil {
+		return v.Instance.String()
+	}
+	if v.IsParam() {
+		return v.Name
+	}
+	return fmt.Sprintf("t%d", v.ID)
+}
+
+// Scheme 表示可能带有类型参数的类型, 每次使用时Vars都会被替换为新的类型变量
+type Scheme struct {
+	Vars []*Var
+	Type Type
+}
+
+// Mono 返回不带类型参数的类型
+func Mono(t Type) *Scheme {
+	return &Scheme{Type: t}
+}
+
+// Generalize 把t中层级深于level的未确定类型变量作为类型参数, 出现在fixed中的类型变量除外
+func Generalize(t Type, level int, fixed ...Type) *Scheme {
+	skip := make(map[*Var]bool)
+	for _, typ := range fixed {
+		for _, v := range FreeVars(typ) {
+			skip[v] = true
+		}
+	}
+	scheme := &Scheme{Type: t}
+	for _, v := range FreeVars(t) {
+		if v.Level > level && !skip[v] {
+			scheme.Vars = append(scheme.Vars, v)
+		}
+	}
+	return scheme
+}
+
+// FreeVars 按出现的顺序返回类型中所有未确定的类型变量
+func FreeVars(t Type) []*Var {
+	var vars []*Var
+	seen := make(map[*Var]bool)
+	var walk func(t Type)
+	walk = func(t Type) {
+		switch t := Resolve(t).(type) {
+		case *Var:
+			if !seen[t] {
+				seen[t] = true
+				vars = append(vars, t)
+			}
+		case *Array:
+			walk(t.Elem)
+		case *Map:
+			walk(t.Key)
+			walk(t.Value)
+		case *Function:
+			for _, param := range t.Params {
+				walk(param)
+			}
+			walk(t.Result)
+		}
+	}
+	walk(t)
+	return vars
+}
+
+// Instantiate 用fresh创建的新类型变量替换类型参数, 返回替换后的类型
+func (s *Scheme) Instantiate(fresh func(v *Var) *Var) Type {
+	if len(s.Vars) == 0 {
+		return s.Type
+	}
+	mapping := make(map[*Var]Type, len(s.Vars))
+	for _, v := range s.Vars {
+		mapping[v] = fresh(v)
+	}
+	return substitute(s.Type, mapping)
+}
+
+// paramNames 为没有名字的类型变量取名时依次使用的名字, 用完后使用 T1、T2 等名字
+var paramNames = []string{"T", "U", "V", "W"}
+
+// String 返回带有类型参数的类型, 推断产生的类型参数显示为 T、U 等名字,
+// 它们的约束在where之后列出, 如 function(T, T) T where T: int | float | string
+func (s *Scheme) String() string {
+	typ, constraints := (&Scheme{Vars: FreeVars(s.Type), Type: s.Type}).named()
+	if len(constraints) == 0 {
+		return typ.String()
+	}
+	return typ.String() + " where " + strings.Join(constraints, ", ")
+}
+
+// named 把Vars中没有名字的类型变量替换为有名字的类型参数, 名字不和类型中已有的类型参数重复.
+// 同时返回这些类型变量的约束, 如 T: int | float
+func (s *Scheme) named() (Type, []string) {
+	used := make(map[string]bool)
+	for _, v := range FreeVars(s.Type) {
+		if v.IsParam() {
+			used[v.Name] = true
+		}
+	}
+	next := 0
+	var constraints []string
+	typ := s.Instantiate(func(v *Var) *Var {
+		if v.IsParam() {
+			return v
+		}
+		name := ""
+		for name == "" || used[name] {
+			if next < len(paramNames) {
+				name = paramNames[next]
+			} else {
+				name = fmt.Sprintf("T%d", next-len(paramNames)+1)
+			}
+			next++
+		}
+		used[name] = true
+		if v.Constraint != 0 {
+			constraints = append(constraints, name+": "+v.Constraint.String())
+		}
+		return NewParam(v.ID, v.Level, name)
+	})
+	return typ, constraints
+}
+
+// Describe 返回不包含类型变量编号的类型名, 未确定的类型变量显示为 T、U 等名字
+func Describe(t Type) string {
+	typ, _ := (&Scheme{Vars: FreeVars(t), Type: t}).named()
+	return typ.String()
+}
+
+// substitute 把类型中出现的类型变量替换为mapping中对应的类型
+func substitute(t Type, mapping map[*Var]Type) Type {
+	switch t := Resolve(t).(type) {
+	case *Var:
+		if replacement, ok := mapping[t]; ok {
+			return replacement
+		}
+		return t
+	case *Array:
+		return &Array{Elem: substitute(t.Elem, mapping)}
+	case *Map:
+		return &Map{Key: substitute(t.Key, mapping), Value: substitute(t.Value, mapping)}
+	case *Function:
+		params := make([]Type, 0, len(t.Params))
+		for _, param := range t.Params {
+			params = append(params, substitute(param, mapping))
+		}
+		return &Function{Params: params, Result: substitute(t.Result, mapping)}
+	default:
+		return t
+	}
+}
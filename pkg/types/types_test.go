@@ -0,0 +1,183 @@
+package types
+
+import "testing"
+
+func TestUnify(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b func() Type
+		want bool
+	}{
+		{
+			name: "same_basic",
+			a:    func() Type { return Int },
+			b:    func() Type { return Int },
+			want: true,
+		},
+		{
+			name: "different_basic",
+			a:    func() Type { return Int },
+			b:    func() Type { return Float },
+			want: false,
+		},
+		{
+			name: "var_binds_to_array",
+			a:    func() Type { return NewVar(1, 0, 0) },
+			b:    func() Type { return &Array{Elem: String} },
+			want: true,
+		},
+		{
+			name: "constraint_rejects_type",
+			a:    func() Type { return NewVar(1, 0, Numeric) },
+			b:    func() Type { return Bool },
+			want: false,
+		},
+		{
+			name: "constraints_intersect",
+			a:    func() Type { return NewVar(1, 0, Numeric) },
+			b:    func() Type { return NewVar(2, 0, MapKey) },
+			want: true,
+		},
+		{
+			name: "disjoint_constraints",
+			a:    func() Type { return NewVar(1, 0, Numeric) },
+			b:    func() Type { return NewVar(2, 0, KindBool) },
+			want: false,
+		},
+		{
+			name: "function_arity",
+			a:    func() Type { return &Function{Params: []Type{Int}, Result: Int} },
+			b:    func() Type { return &Function{Params: []Type{Int, Int}, Result: Int} },
+			want: false,
+		},
+		{
+			name: "struct_is_nominal",
+			a:    func() Type { return &Struct{Name: "Point"} },
+			b:    func() Type { return &Struct{Name: "Point"} },
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unify(tt.a(), tt.b()); got != tt.want {
+				t.Errorf("Unify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifyOccursCheck(t *testing.T) {
+	v := NewVar(1, 0, 0)
+	if Unify(v, &Array{Elem: v}) {
+		t.Errorf("Unify(t1, []t1) = true, want false")
+	}
+}
+
+func TestUnifyBindsVars(t *testing.T) {
+	elem := NewVar(1, 0, 0)
+	result := NewVar(2, 0, 0)
+	a := &Function{Params: []Type{&Array{Elem: elem}}, Result: result}
+	b := &Function{Params: []Type{&Array{Elem: Int}}, Result: Bool}
+	if !Unify(a, b) {
+		t.Fatalf("Unify(%s, %s) = false, want true", a, b)
+	}
+	if got, want := a.String(), "function([]int) bool"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	key, value := NewVar(1, 0, MapKey), NewVar(2, 0, 0)
+	scheme := &Scheme{
+		Vars: []*Var{key, value},
+		Type: &Function{Params: []Type{&Map{Key: key, Value: value}}, Result: &Array{Elem: key}},
+	}
+	id := 10
+	typ := scheme.Instantiate(func(v *Var) *Var {
+		id++
+		return NewVar(id, 0, v.Constraint)
+	})
+	if got, want := typ.String(), "function(map[t11]t12) []t11"; got != want {
+		t.Errorf("Instantiate() = %s, want %s", got, want)
+	}
+	if !Unify(typ, &Function{Params: []Type{&Map{Key: String, Value: Int}}, Result: &Array{Elem: String}}) {
+		t.Errorf("Unify() = false, want true")
+	}
+	if key.Instance != nil || value.Instance != nil {
+		t.Errorf("Instantiate() changed the type parameters of the scheme")
+	}
+}
+
+func TestUnifyParam(t *testing.T) {
+	param := NewParam(1, 1, "T")
+	if Unify(param, Int) {
+		t.Errorf("Unify(T, int) = true, want false")
+	}
+	if Unify(param, NewParam(2, 1, "U")) {
+		t.Errorf("Unify(T, U) = true, want false")
+	}
+	if Restrict(param, Numeric) {
+		t.Errorf("Restrict(T, %s) = true, want false", Numeric)
+	}
+	v := NewVar(3, 1, 0)
+	if !Unify(param, v) || Resolve(v) != param {
+		t.Errorf("Unify(T, t3) did not bind t3 to T")
+	}
+}
+
+func TestGeneralize(t *testing.T) {
+	outer := NewVar(1, 0, 0)
+	inner := NewVar(2, 1, Numeric)
+	pending := NewVar(3, 1, 0)
+	typ := &Function{Params: []Type{outer, inner, pending}, Result: inner}
+	scheme := Generalize(typ, 0, &Array{Elem: pending})
+	if len(scheme.Vars) != 1 || scheme.Vars[0] != inner {
+		t.Fatalf("Generalize() vars = %v, want [t2]", scheme.Vars)
+	}
+	// 和外层类型变量统一后, 类型变量的层级降低, 不再被泛化
+	local := NewVar(4, 1, 0)
+	if !Unify(outer, &Array{Elem: local}) {
+		t.Fatalf("Unify() = false, want true")
+	}
+	if scheme := Generalize(local, 0); len(scheme.Vars) != 0 {
+		t.Errorf("Generalize() vars = %v, want none", scheme.Vars)
+	}
+}
+
+func TestAssignKey(t *testing.T) {
+	if !Assign(Key, Int) || !Assign(Key, String) {
+		t.Errorf("Assign(%s, int|string) = false, want true", Key)
+	}
+	if Assign(Key, Float) || Assign(Int, Key) {
+		t.Errorf("Assign() accepted a value that is not a valid key")
+	}
+	if Restrict(Key, Ordered) {
+		t.Errorf("Restrict(%s, %s) = true, want false", Key, Ordered)
+	}
+	if !Unify(NewVar(1, 0, MapKey), Key) || Unify(NewVar(2, 0, Ordered), Key) {
+		t.Errorf("Unify() with %s does not respect constraints", Key)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	param := NewParam(1, 1, "T")
+	v, w := NewVar(2, 1, 0), NewVar(3, 1, Numeric)
+	typ := &Function{Params: []Type{param, v, &Array{Elem: w}}, Result: v}
+	if got, want := Describe(typ), "function(T, U, []V) U"; got != want {
+		t.Errorf("Describe() = %s, want %s", got, want)
+	}
+	if v.Instance != nil || w.Instance != nil {
+		t.Errorf("Describe() changed the type variables")
+	}
+}
+
+func TestSchemeString(t *testing.T) {
+	v, w := NewVar(1, 1, Ordered), NewVar(2, 1, 0)
+	scheme := &Scheme{Vars: []*Var{v, w}, Type: &Function{Params: []Type{v, w}, Result: v}}
+	if got, want := scheme.String(), "function(T, U) T where T: int | float | string"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+	if got, want := Mono(&Array{Elem: Int}).String(), "[]int"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+}
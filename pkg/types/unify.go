@@ -0,0 +1,155 @@
+package types
+
+// Resolve 沿着已确定的类型变量找到它代表的类型, 未确定的类型变量返回它自身
+func Resolve(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.Instance == nil {
+			return t
+		}
+		t = v.Instance
+	}
+}
+
+// KindOf 返回类型的种类, 未确定的类型变量返回它的约束
+func KindOf(t Type) Kind {
+	switch t := Resolve(t).(type) {
+	case *Basic:
+		return t.kind
+	case *Array:
+		return KindArray
+	case *Map:
+		return KindMap
+	case *Function:
+		return KindFunction
+	case *Struct:
+		return KindStruct
+	case *Var:
+		return t.Constraint
+	}
+	return 0
+}
+
+// Unify 确定类型中的类型变量, 使a和b成为相同的类型, 无法统一时返回false.
+// 统一失败时已经确定的类型变量不会被撤销
+func Unify(a, b Type) bool {
+	a, b = Resolve(a), Resolve(b)
+	if a == b {
+		return true
+	}
+	if v, ok := a.(*Var); ok {
+		return bind(v, b)
+	}
+	if v, ok := b.(*Var); ok {
+		return bind(v, a)
+	}
+	switch a := a.(type) {
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && Unify(a.Elem, b.Elem)
+	case *Map:
+		b, ok := b.(*Map)
+		return ok && Unify(a.Key, b.Key) && Unify(a.Value, b.Value)
+	case *Function:
+		b, ok := b.(*Function)
+		if !ok || len(a.Params) != len(b.Params) {
+			return false
+		}
+		for i := range a.Params {
+			if !Unify(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+		return Unify(a.Result, b.Result)
+	}
+	// 基本类型和结构体类型只和自身相同
+	return false
+}
+
+// Restrict 要求类型属于constraint中的种类, 未确定的类型变量会记录这个约束.
+// 类型参数可以代表任意类型, 因此不满足任何约束
+func Restrict(t Type, constraint Kind) bool {
+	v, ok := Resolve(t).(*Var)
+	if !ok {
+		return KindOf(t)&constraint != 0
+	}
+	if v.IsParam() {
+		return false
+	}
+	if v.Constraint != 0 {
+		constraint &= v.Constraint
+		if constraint == 0 {
+			return false
+		}
+	}
+	v.Constraint = constraint
+	return true
+}
+
+// Assign 检查类型为value的值能否用在需要target类型的地方, 如传给参数或作为字典的键.
+// int和string的值都可以作为 int | string 使用, 其他情况下两个类型必须相同
+func Assign(target, value Type) bool {
+	if Resolve(target) == Key && Restrict(value, MapKey) {
+		return true
+	}
+	return Unify(target, value)
+}
+
+// bind 把类型变量v确定为t, t必须满足v的约束, 并且不能包含v自身
+func bind(v *Var, t Type) bool {
+	if other, ok := t.(*Var); ok {
+		// 类型参数不能被确定为其他类型, 只能让另一个类型变量代表它
+		if v.IsParam() {
+			if other.IsParam() {
+				return false
+			}
+			v, other = other, v
+		}
+		// 两个类型变量的约束取交集
+		if v.Constraint != 0 && !Restrict(other, v.Constraint) {
+			return false
+		}
+		if other.Level > v.Level {
+			other.Level = v.Level
+		}
+		v.Instance = other
+		return true
+	}
+	if v.IsParam() {
+		return false
+	}
+	if v.Constraint != 0 && KindOf(t)&v.Constraint == 0 {
+		return false
+	}
+	if !adjust(v, t) {
+		return false
+	}
+	v.Instance = t
+	return true
+}
+
+// adjust 检查类型变量v是否出现在t中, 例如 t1 和 []t1 无法统一.
+// 同时把t中类型变量的层级调整为不深于v的层级, 它们和v一样不能在更深的层级被泛化
+func adjust(v *Var, t Type) bool {
+	switch t := Resolve(t).(type) {
+	case *Var:
+		if t == v {
+			return false
+		}
+		if t.Level > v.Level {
+			t.Level = v.Level
+		}
+	case *Array:
+		return adjust(v, t.Elem)
+	case *Map:
+		return adjust(v, t.Key) && adjust(v, t.Value)
+	case *Function:
+		for _, param := range t.Params {
+			if !adjust(v, param) {
+				return false
+			}
+		}
+		return adjust(v, t.Result)
+	}
+	return true
+}